@@ -0,0 +1,172 @@
+package r2
+
+import (
+	"net/http"
+	"net/url"
+	stdpath "path"
+	"strings"
+)
+
+// ServeFiles registers GET and HEAD routes for the path prefix followed by "/*"
+// with the optional ms, which serve files from the fsys in the same way as the
+// [http.FileServer]. The wildcard path parameter is used as the name of the
+// file to be served, and requests whose file names contain ".." path elements
+// are rejected with bad request responses.
+//
+// A request for a directory is served with the "index.html" in it if any, or a
+// listing of its contents otherwise. Since the "/*" registers a TSR route for
+// the path prefix (see the [Router.Handle]), a request for the path prefix is
+// redirected to the one with a trailing slash, which serves the root of the
+// fsys.
+func (r *Router) ServeFiles(
+	prefix string,
+	fsys http.FileSystem,
+	ms ...Middleware,
+) {
+	fileServer := http.FileServer(fsys)
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		name := PathParam(req, "*")
+		if containsDotDot(name) {
+			http.Error(
+				rw,
+				"invalid URL path",
+				http.StatusBadRequest,
+			)
+			return
+		}
+
+		// Serve a shallow copy of the req whose path is the name, as
+		// the http.StripPrefix does.
+		req2 := new(http.Request)
+		*req2 = *req
+		req2.URL = new(url.URL)
+		*req2.URL = *req.URL
+		req2.URL.Path = "/" + name
+		req2.URL.RawPath = ""
+		fileServer.ServeHTTP(rw, req2)
+	})
+
+	path := strings.TrimSuffix(prefix, "/") + "/*"
+	r.Handle(http.MethodGet, path, h, ms...)
+	r.Handle(http.MethodHead, path, h, ms...)
+}
+
+// ServeSPA registers the file named fallback in the fsys as the SPA
+// (Single-Page Application) fallback for the path prefix with the optional ms,
+// which is served for requests that would otherwise be served by the
+// [Router.NotFoundHandler], so that the SPA can handle its client-side routes.
+//
+// A SPA fallback only serves GET and HEAD requests whose paths have the path
+// prefix and whose Accept headers accept "text/html". Requests whose paths end
+// with file extensions (such as "/app/main.js") are never served, since they
+// look like requests for missing asset files. When more than one SPA fallback
+// can serve a request, the one with the longest path prefix is used.
+//
+// Note that the r.Host is not taken into account, and the [Router.Match] still
+// reports the [MatchNotFound] for requests served by SPA fallbacks.
+func (r *Router) ServeSPA(
+	prefix string,
+	fsys http.FileSystem,
+	fallback string,
+	ms ...Middleware,
+) {
+	if r.Parent != nil {
+		r.Parent.ServeSPA(
+			r.PathPrefix+prefix,
+			fsys,
+			fallback,
+			append(r.Middlewares, ms...)...,
+		)
+		return
+	}
+
+	var h http.Handler = http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		f, err := fsys.Open(fallback)
+		if err != nil {
+			http.NotFound(rw, req)
+			return
+		}
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil || fi.IsDir() {
+			http.NotFound(rw, req)
+			return
+		}
+
+		http.ServeContent(rw, req, fi.Name(), fi.ModTime(), f)
+	})
+
+	ms = append(r.Middlewares, ms...)
+	for i := len(ms) - 1; i >= 0; i-- {
+		if ms[i] != nil {
+			h = ms[i].ChainHTTPHandler(h)
+		}
+	}
+
+	sf := &spaFallback{
+		prefix:  strings.TrimSuffix(r.PathPrefix+prefix, "/"),
+		handler: h,
+	}
+
+	r.tableMutex.Lock()
+	defer r.tableMutex.Unlock()
+
+	tb := r.writableTable()
+	i := 0
+	for i < len(tb.spaFallbacks) &&
+		len(tb.spaFallbacks[i].prefix) >= len(sf.prefix) {
+		i++
+	}
+
+	sfs := make([]*spaFallback, 0, len(tb.spaFallbacks)+1)
+	sfs = append(sfs, tb.spaFallbacks[:i]...)
+	sfs = append(sfs, sf)
+	tb.spaFallbacks = append(sfs, tb.spaFallbacks[i:]...)
+	r.table.Store(tb)
+}
+
+// spaFallback is a SPA fallback registered by the [Router.ServeSPA].
+type spaFallback struct {
+	prefix  string
+	handler http.Handler
+}
+
+// serves reports whether the sf serves the req.
+func (sf *spaFallback) serves(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	path := req.URL.Path
+	if path != sf.prefix && !strings.HasPrefix(path, sf.prefix+"/") {
+		return false
+	}
+
+	if stdpath.Ext(path) != "" {
+		return false
+	}
+
+	return acceptQuality(req.Header["Accept"], "text/html") > 0
+}
+
+// containsDotDot reports whether the name contains any ".." path element, in
+// which both '/' and '\' are treated as separators.
+func containsDotDot(name string) bool {
+	if !strings.Contains(name, "..") {
+		return false
+	}
+
+	for _, e := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\'
+	}) {
+		if e == ".." {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build go1.16
+// +build go1.16
+
+package r2
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// ServeFS is like the [Router.ServeFiles], but serves files from the fsys, such
+// as an [embed.FS].
+func (r *Router) ServeFS(prefix string, fsys fs.FS, ms ...Middleware) {
+	r.ServeFiles(prefix, http.FS(fsys), ms...)
+}
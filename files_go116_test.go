@@ -0,0 +1,43 @@
+//go:build go1.16
+// +build go1.16
+
+package r2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRouterServeFS(t *testing.T) {
+	r := &Router{}
+	r.ServeFS("/static", fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("index")},
+		"foo.txt":    &fstest.MapFile{Data: []byte("foo")},
+	})
+
+	for _, tt := range []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/static/", http.StatusOK, "index"},
+		{"/static/foo.txt", http.StatusOK, "foo"},
+		{
+			"/static/bar.txt",
+			http.StatusNotFound,
+			"404 page not found\n",
+		},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got, want := rec.Code, tt.wantStatus; got != want {
+			t.Errorf("%s: got %d, want %d", tt.target, got, want)
+		} else if got, want := rec.Body.String(),
+			tt.wantBody; got != want {
+			t.Errorf("%s: got %q, want %q", tt.target, got, want)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package r2
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouterServeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "r2.TestRouterServeFiles")
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"index.html":     "index",
+		"foo.txt":        "foo",
+		"bar/index.html": "bar index",
+		"baz/qux.txt":    "qux",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		if err := ioutil.WriteFile(
+			name,
+			[]byte(content),
+			0o644,
+		); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+	}
+
+	r := &Router{}
+	r.ServeFiles("/static/", http.Dir(dir), MatchHeader("X-Foo", "bar"))
+	r.Sub("/sub").ServeFiles("", http.Dir(filepath.Join(dir, "baz")))
+
+	for _, tt := range []struct {
+		method       string
+		target       string
+		header       http.Header
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			method:     http.MethodGet,
+			target:     "/static/foo.txt",
+			wantStatus: http.StatusOK,
+			wantBody:   "foo",
+		},
+		{
+			method:     http.MethodHead,
+			target:     "/static/foo.txt",
+			wantStatus: http.StatusOK,
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/static/",
+			wantStatus: http.StatusOK,
+			wantBody:   "index",
+		},
+		{
+			method:       http.MethodGet,
+			target:       "/static",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "/static/",
+		},
+		{
+			method:       http.MethodGet,
+			target:       "/static/bar",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "bar/",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/static/bar/",
+			wantStatus: http.StatusOK,
+			wantBody:   "bar index",
+		},
+		{
+			method:       http.MethodGet,
+			target:       "/static/index.html",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "./",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/static/%2e%2e/foo.txt",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid URL path\n",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/static/bar/..%5cfoo.txt",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid URL path\n",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/static/..foo.txt",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found\n",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/static/qux.txt",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found\n",
+		},
+		{
+			method:     http.MethodPost,
+			target:     "/static/foo.txt",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method Not Allowed\n",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/sub/qux.txt",
+			header:     http.Header{},
+			wantStatus: http.StatusOK,
+			wantBody:   "qux",
+		},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Header.Set("X-Foo", "bar")
+		if tt.header != nil {
+			req.Header = tt.header
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		recr := rec.Result()
+		if got, want := recr.StatusCode, tt.wantStatus; got != want {
+			t.Errorf("%s: got %d, want %d", tt.target, got, want)
+		} else if got, want := recr.Header.Get(
+			"Location",
+		), tt.wantLocation; got != want {
+			t.Errorf("%s: got %q, want %q", tt.target, got, want)
+		} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		} else if want := tt.wantBody; want != "" && string(b) != want {
+			t.Errorf("%s: got %q, want %q", tt.target, b, want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/static/foo.txt", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusNotFound; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func TestRouterServeSPA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "r2.TestRouterServeSPA")
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"index.html":       "index",
+		"admin.html":       "admin",
+		"assets/style.css": "style",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		if err := ioutil.WriteFile(
+			name,
+			[]byte(content),
+			0o644,
+		); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+	}
+
+	r := &Router{
+		NotFoundHandler: http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(`{"error":"not found"}`))
+		}),
+	}
+	r.ServeSPA("/", http.Dir(dir), "/index.html")
+	r.Handle(http.MethodGet, "/api/users", http.NotFoundHandler())
+	r.ServeFiles("/assets", http.Dir(filepath.Join(dir, "assets")))
+	r.Sub("/admin").ServeSPA(
+		"",
+		http.Dir(dir),
+		"admin.html",
+		MiddlewareFunc(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(
+				rw http.ResponseWriter,
+				req *http.Request,
+			) {
+				rw.Header().Set("X-Admin", "true")
+				next.ServeHTTP(rw, req)
+			})
+		}),
+	)
+	r.ServeSPA("/missing", http.Dir(dir), "missing.html")
+	r.ServeSPA("/dir", http.Dir(dir), "assets")
+
+	for _, tt := range []struct {
+		method     string
+		target     string
+		accept     string
+		wantStatus int
+		wantBody   string
+		wantAdmin  string
+	}{
+		{
+			method:     http.MethodGet,
+			target:     "/users/1",
+			accept:     "text/html,application/xhtml+xml,*/*;q=0.8",
+			wantStatus: http.StatusOK,
+			wantBody:   "index",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/",
+			accept:     "*/*",
+			wantStatus: http.StatusOK,
+			wantBody:   "index",
+		},
+		{
+			method:     http.MethodHead,
+			target:     "/users/1",
+			accept:     "text/html",
+			wantStatus: http.StatusOK,
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/users/1",
+			accept:     "application/json",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"not found"}`,
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/api/posts",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"not found"}`,
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/main.js",
+			accept:     "text/html",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"not found"}`,
+		},
+		{
+			method:     http.MethodPost,
+			target:     "/users/1",
+			accept:     "text/html",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"not found"}`,
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/assets/style.css",
+			accept:     "text/html",
+			wantStatus: http.StatusOK,
+			wantBody:   "style",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/admin/settings",
+			accept:     "text/html",
+			wantStatus: http.StatusOK,
+			wantBody:   "admin",
+			wantAdmin:  "true",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/admin",
+			accept:     "text/html",
+			wantStatus: http.StatusOK,
+			wantBody:   "admin",
+			wantAdmin:  "true",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/administrator",
+			accept:     "text/html",
+			wantStatus: http.StatusOK,
+			wantBody:   "index",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/missing/foo",
+			accept:     "text/html",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found\n",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/dir/foo",
+			accept:     "text/html",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found\n",
+		},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got, want := rec.Code, tt.wantStatus; got != want {
+			t.Errorf("%s: got %d, want %d", tt.target, got, want)
+		} else if got, want := rec.Body.String(),
+			tt.wantBody; got != want {
+			t.Errorf("%s: got %q, want %q", tt.target, got, want)
+		} else if got, want := rec.Header().Get("X-Admin"),
+			tt.wantAdmin; got != want {
+			t.Errorf("%s: got %q, want %q", tt.target, got, want)
+		}
+	}
+
+	if got, want := r.Match(
+		http.MethodGet,
+		"/users/1",
+	).Status, MatchNotFound; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, concurrent := range []bool{false, true} {
+		r := &Router{Concurrent: concurrent}
+		r.ServeSPA("/", http.Dir(dir), "/index.html")
+		r.ServeSPA("/admin", http.Dir(dir), "/admin.html")
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/foo", nil)
+		req.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got, want := rec.Body.String(), "admin"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		r.Handle(http.MethodGet, "/foo", http.NotFoundHandler())
+
+		req = httptest.NewRequest(http.MethodGet, "/bar", nil)
+		req.Header.Set("Accept", "text/html")
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got, want := rec.Body.String(), "index"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
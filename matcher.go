@@ -0,0 +1,236 @@
+package r2
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Matcher is a [Middleware] that is used to match the [http.Request] after its
+// method and path are matched. A route registered with at least one Matcher
+// only matches requests that are matched by all of its Matchers. See the
+// [Router.Handle] for more details.
+type Matcher interface {
+	Middleware
+
+	// Match reports whether the req is matched.
+	Match(req *http.Request) bool
+}
+
+// MatcherFunc is an adapter to allow the use of an ordinary function as a
+// [Matcher].
+type MatcherFunc func(req *http.Request) bool
+
+// ChainHTTPHandler implements the [Middleware]. It returns the next as is.
+func (mf MatcherFunc) ChainHTTPHandler(next http.Handler) http.Handler {
+	return next
+}
+
+// Match implements the [Matcher].
+func (mf MatcherFunc) Match(req *http.Request) bool {
+	return mf(req)
+}
+
+// MatchHeader returns a [Matcher] that matches requests with at least one
+// header value for the key equal to the value.
+func MatchHeader(key, value string) Matcher {
+	key = http.CanonicalHeaderKey(key)
+	return MatcherFunc(func(req *http.Request) bool {
+		for _, v := range req.Header[key] {
+			if v == value {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// MatchQuery returns a [Matcher] that matches requests with at least one query
+// parameter value for the key equal to the value.
+func MatchQuery(key, value string) Matcher {
+	return MatcherFunc(func(req *http.Request) bool {
+		for _, v := range req.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// Produces returns a [Matcher] that declares the mediaTypes (such as
+// "application/json") that the route produces. It matches requests whose
+// Accept header accepts at least one of the mediaTypes, or requests without an
+// Accept header.
+//
+// Among all matched routes with the same method and path, the one whose
+// declared media types are most preferred by the Accept header (according to
+// its quality values) is chosen, and routes with Produces are preferred over
+// routes without one. When no route matches a request only because of its
+// Accept header, the [Router.NotAcceptableHandler] is used.
+func Produces(mediaTypes ...string) Matcher {
+	return &producesMatcher{
+		mediaTypes: normalizeMediaTypes(mediaTypes, false),
+	}
+}
+
+// Consumes returns a [Matcher] that declares the mediaTypes (such as
+// "application/json" and "text/*") that the route consumes. It matches requests
+// whose Content-Type header matches at least one of the mediaTypes. A request
+// without a Content-Type header is considered to be "application/octet-stream".
+//
+// When no route matches a request only because of its Content-Type header, the
+// [Router.UnsupportedMediaTypeHandler] is used.
+func Consumes(mediaTypes ...string) Matcher {
+	return &consumesMatcher{
+		mediaTypes: normalizeMediaTypes(mediaTypes, true),
+	}
+}
+
+// producesMatcher is the [Matcher] returned by the [Produces].
+type producesMatcher struct {
+	mediaTypes []string
+}
+
+// ChainHTTPHandler implements the [Middleware]. It returns the next as is.
+func (pm *producesMatcher) ChainHTTPHandler(next http.Handler) http.Handler {
+	return next
+}
+
+// Match implements the [Matcher].
+func (pm *producesMatcher) Match(req *http.Request) bool {
+	return pm.quality(req) > 0
+}
+
+// quality returns the highest quality value of the media types of the pm in the
+// Accept header of the req. It returns 1 if the req has no Accept header.
+func (pm *producesMatcher) quality(req *http.Request) float64 {
+	accept := req.Header["Accept"]
+	if len(accept) == 0 {
+		return 1
+	}
+
+	var q float64
+	for _, mt := range pm.mediaTypes {
+		if mtq := acceptQuality(accept, mt); mtq > q {
+			q = mtq
+		}
+	}
+
+	return q
+}
+
+// consumesMatcher is the [Matcher] returned by the [Consumes].
+type consumesMatcher struct {
+	mediaTypes []string
+}
+
+// ChainHTTPHandler implements the [Middleware]. It returns the next as is.
+func (cm *consumesMatcher) ChainHTTPHandler(next http.Handler) http.Handler {
+	return next
+}
+
+// Match implements the [Matcher].
+func (cm *consumesMatcher) Match(req *http.Request) bool {
+	ct := req.Header.Get("Content-Type")
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+
+	for _, mt := range cm.mediaTypes {
+		if mediaRangeSpecificity(mt, ct) >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeMediaTypes returns the mediaTypes in lower case. It panics if any of
+// the mediaTypes is invalid, or if the mediaTypes is empty.
+func normalizeMediaTypes(mediaTypes []string, allowRanges bool) []string {
+	if len(mediaTypes) == 0 {
+		panic("r2: media types cannot be empty")
+	}
+
+	nmts := make([]string, 0, len(mediaTypes))
+	for _, mt := range mediaTypes {
+		mt = strings.ToLower(strings.TrimSpace(mt))
+
+		i := strings.IndexByte(mt, '/')
+		if i <= 0 || i == len(mt)-1 || strings.ContainsAny(mt, " ;,") {
+			panic("r2: invalid media type " + strconv.Quote(mt))
+		}
+
+		if !allowRanges && strings.IndexByte(mt, '*') >= 0 {
+			panic("r2: media type cannot be a range")
+		}
+
+		nmts = append(nmts, mt)
+	}
+
+	return nmts
+}
+
+// acceptQuality returns the quality value of the mediaType in the accept, which
+// is the values of an Accept header. It returns 0 if the mediaType is not
+// acceptable.
+func acceptQuality(accept []string, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, a := range accept {
+		for _, mr := range strings.Split(a, ",") {
+			var params string
+			if i := strings.IndexByte(mr, ';'); i >= 0 {
+				mr, params = mr[:i], mr[i+1:]
+			}
+
+			mr = strings.ToLower(strings.TrimSpace(mr))
+			s := mediaRangeSpecificity(mr, mediaType)
+			if s <= specificity {
+				continue
+			}
+
+			q, specificity = 1, s
+			for _, p := range strings.Split(params, ";") {
+				p = strings.TrimSpace(p)
+				if len(p) < 2 || (p[0] != 'q' && p[0] != 'Q') ||
+					p[1] != '=' {
+					continue
+				}
+
+				pq, err := strconv.ParseFloat(p[2:], 64)
+				if err != nil || pq < 0 || pq > 1 {
+					pq = 0
+				}
+
+				q = pq
+			}
+		}
+	}
+
+	return q
+}
+
+// mediaRangeSpecificity returns the specificity of the mediaRange when it
+// matches the mediaType, which is 2 for the same media type, 1 for a
+// "type/*" and 0 for the "*/*". It returns -1 if they do not match.
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") &&
+		strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1]):
+		return 1
+	}
+
+	return -1
+}
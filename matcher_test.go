@@ -0,0 +1,207 @@
+package r2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatcherFuncChainHTTPHandler(t *testing.T) {
+	mf := MatcherFunc(func(req *http.Request) bool {
+		return false
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	mf.ChainHTTPHandler(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "foobar")
+	})).ServeHTTP(rec, req)
+
+	recb := rec.Body.String()
+	if want := "foobar"; recb != want {
+		t.Errorf("got %q, want %q", recb, want)
+	}
+}
+
+func TestMatcherFuncMatch(t *testing.T) {
+	mf := MatcherFunc(func(req *http.Request) bool {
+		return req.Host == "www.example.com"
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, want := mf.Match(req), false; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+
+	req.Host = "www.example.com"
+	if got, want := mf.Match(req), true; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+}
+
+func TestMatchHeader(t *testing.T) {
+	m := MatchHeader("x-api-version", "2")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, want := m.Match(req), false; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+
+	req.Header.Add("X-API-Version", "1")
+	if got, want := m.Match(req), false; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+
+	req.Header.Add("X-API-Version", "2")
+	if got, want := m.Match(req), true; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+}
+
+func TestMatchQuery(t *testing.T) {
+	m := MatchQuery("version", "2")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, want := m.Match(req), false; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?version=1", nil)
+	if got, want := m.Match(req), false; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?version=1&version=2", nil)
+	if got, want := m.Match(req), true; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+}
+
+func TestProduces(t *testing.T) {
+	for _, mts := range [][]string{
+		nil,
+		{"json"},
+		{"application/"},
+		{"application/json;q=1"},
+		{"application/*"},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+
+			Produces(mts...)
+		}()
+	}
+
+	m := Produces("Application/JSON", "text/csv")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	m.ChainHTTPHandler(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "foobar")
+	})).ServeHTTP(rec, req)
+
+	recb := rec.Body.String()
+	if want := "foobar"; recb != want {
+		t.Errorf("got %q, want %q", recb, want)
+	}
+
+	pm := m.(*producesMatcher)
+	for _, tc := range []struct {
+		accept []string
+		q      float64
+	}{
+		{nil, 1},
+		{[]string{"text/html"}, 0},
+		{[]string{"application/json"}, 1},
+		{[]string{"text/html", "application/json;q=0.5"}, 0.5},
+		{[]string{"application/*; q=0.5, text/csv;Q=0.8"}, 0.8},
+		{[]string{"*/*;q=0.1, application/json;q=0"}, 0.1},
+		{[]string{"*/*;q=0.1, application/*;q=0, text/csv;q=0"}, 0},
+		{[]string{"application/json;charset=utf-8;q=foo"}, 0},
+		{[]string{"application/json;q=2"}, 0},
+		{[]string{"application/json;level=1"}, 1},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header["Accept"] = tc.accept
+		if got, want := pm.quality(req), tc.q; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		} else if got, want := m.Match(req), tc.q > 0; got != want {
+			t.Errorf("got %t, want %t", got, want)
+		}
+	}
+}
+
+func TestConsumes(t *testing.T) {
+	for _, mts := range [][]string{
+		nil,
+		{"json"},
+		{"/json"},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+
+			Consumes(mts...)
+		}()
+	}
+
+	m := Consumes("application/json", "text/*")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	m.ChainHTTPHandler(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "foobar")
+	})).ServeHTTP(rec, req)
+
+	recb := rec.Body.String()
+	if want := "foobar"; recb != want {
+		t.Errorf("got %q, want %q", recb, want)
+	}
+
+	for _, tc := range []struct {
+		contentType string
+		match       bool
+	}{
+		{"", false},
+		{"application/json", true},
+		{"Application/JSON; charset=utf-8", true},
+		{"text/plain", true},
+		{"image/png", false},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+
+		if got, want := m.Match(req), tc.match; got != want {
+			t.Errorf("got %t, want %t", got, want)
+		}
+	}
+
+	m = Consumes("*/*")
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	if got, want := m.Match(req), true; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package r2
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mount registers catch-all routes (see the [Router.Handle]) for the path
+// prefix followed by "/*" with the optional ms, which serve requests with the h
+// as if the h were mounted at the path prefix. The path of each request seen by
+// the h is the value of the wildcard path parameter with a leading '/', such as
+// "/bar" for "/prefix/bar", and its escaped form is kept as well. The
+// [OriginalPath] can be used to get the path before the rewrite.
+//
+// Since the "/*" registers a TSR route for the path prefix, a request for the
+// path prefix is redirected to the one with a trailing slash, which is served
+// with the h as "/".
+func (r *Router) Mount(prefix string, h http.Handler, ms ...Middleware) {
+	mh := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// The h gets a copy of the data of the req, so that the
+		// original stays intact for the code that runs after the h
+		// returns (such as the ms) even if the h is a [Router].
+		ctx := req.Context()
+		if d, ok := ctx.Value(dataContextKey).(*data); ok {
+			dc := *d
+			ctx = context.WithValue(ctx, dataContextKey, &dc)
+		}
+
+		if _, ok := ctx.Value(originalPathContextKey).(string); !ok {
+			ctx = context.WithValue(
+				ctx,
+				originalPathContextKey,
+				req.URL.Path,
+			)
+		}
+
+		rest := PathParam(req, "*")
+		u := *req.URL
+		u.Path = "/" + rest
+		u.RawPath = ""
+		if req.URL.RawPath != "" {
+			u.RawPath = escapedSuffix(req.URL.RawPath, rest)
+		}
+
+		req = req.WithContext(ctx)
+		req.URL = &u
+		h.ServeHTTP(rw, req)
+	})
+
+	r.Handle("", strings.TrimSuffix(prefix, "/")+"/*", mh, ms...)
+}
+
+// escapedSuffix returns the suffix of the rawPath that follows a '/' and whose
+// unescaped form is the s, with the '/' included. It returns empty string if
+// not found.
+func escapedSuffix(rawPath, s string) string {
+	for i := len(rawPath) - 1; i >= 0; i-- {
+		if rawPath[i] != '/' {
+			continue
+		}
+
+		if us, err := url.PathUnescape(rawPath[i+1:]); err != nil {
+			break
+		} else if us == s {
+			return rawPath[i:]
+		} else if len(us) > len(s) {
+			break
+		}
+	}
+
+	return ""
+}
+
+// Attach merges the routes registered with the sr, which must be an independent
+// [Router] without a [Router.Parent], into the r under the path prefix with the
+// optional ms, as if they were registered through the r.Sub(prefix, ms...) with
+// the sr.Middlewares performing after the ms. The routes registered with a host
+// keep it, and the others use the r.Host (or the one of its Parent).
+//
+// Only the routes registered with the sr at the time of the call are merged,
+// and the sr is left unchanged. The TSR (Trailing Slash Redirect) routes are
+// registered again as needed, but the handlers of the sr (such as the
+// [Router.NotFoundHandler]) and its SPA fallbacks (see the [Router.ServeSPA])
+// are not merged. Use the [Router.Mount] to dispatch requests to the sr instead
+// if they matter.
+//
+// It panics if the sr cannot be attached or any of its routes cannot be merged,
+// such as when a route with the same host, method and path already exists in
+// the r, in which case the r is left unchanged. Use the [Router.TryAttach] to
+// get an error instead.
+func (r *Router) Attach(prefix string, sr *Router, ms ...Middleware) {
+	if err := r.attachHost(r.Host, prefix, sr, ms); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryAttach is like the [Router.Attach], but returns a [*RouteError] describing
+// why the sr cannot be attached or the first route that cannot be merged
+// instead of panicking, in which case the r is left unchanged.
+func (r *Router) TryAttach(prefix string, sr *Router, ms ...Middleware) error {
+	if err := r.attachHost(r.Host, prefix, sr, ms); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// attachHost is like the [Router.TryAttach], but uses the host instead of the
+// r.Host for the routes registered without a host.
+func (r *Router) attachHost(
+	host string,
+	prefix string,
+	sr *Router,
+	ms []Middleware,
+) *RouteError {
+	if r.Parent != nil {
+		if host == "" {
+			host = r.Parent.Host
+		}
+
+		return r.Parent.attachHost(
+			host,
+			r.PathPrefix+prefix,
+			sr,
+			append(r.Middlewares, ms...),
+		)
+	}
+
+	if sr.Parent != nil {
+		return &RouteError{
+			Path:   r.PathPrefix + prefix,
+			Err:    ErrInvalidAttachedRouter,
+			Detail: "attached router cannot have a parent",
+		}
+	} else if sr == r {
+		return &RouteError{
+			Path:   r.PathPrefix + prefix,
+			Err:    ErrInvalidAttachedRouter,
+			Detail: "router cannot be attached to itself",
+		}
+	}
+
+	srtb := sr.loadTable()
+
+	r.tableMutex.Lock()
+	defer r.tableMutex.Unlock()
+
+	tb := r.loadTable().clone()
+	for _, route := range srtb.routes {
+		if route.TSR {
+			continue
+		}
+
+		rhost := route.Host
+		if rhost == "" {
+			rhost = host
+		}
+
+		path := r.PathPrefix + prefix + route.Pattern
+
+		rms := make([]Middleware, 0, len(ms)+len(sr.Middlewares)+
+			len(route.middlewares))
+		rms = append(rms, ms...)
+		rms = append(rms, sr.Middlewares...)
+		rms = append(rms, route.middlewares...)
+
+		rr, err := r.prepareRoute(
+			tb,
+			rhost,
+			route.Method,
+			path,
+			route.handler,
+			rms,
+		)
+		if err != nil {
+			err.Method, err.Path = route.Method, path
+			return err
+		}
+
+		r.commitRoute(tb, rr)
+	}
+
+	r.table.Store(tb)
+
+	return nil
+}
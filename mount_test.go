@@ -0,0 +1,370 @@
+package r2
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterMount(t *testing.T) {
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.Method + " " + req.URL.Path + " " +
+			req.URL.RawPath + " " + OriginalPath(req) + " " +
+			PathParam(req, "tenant")))
+	})
+
+	r := &Router{}
+	r.Mount("/foo/", h)
+
+	sr := r.Sub("/t/:tenant")
+	sr.Mount("/api", h, MatchHeader("X-Foo", "bar"))
+
+	for _, tt := range []struct {
+		method     string
+		target     string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			method:     http.MethodGet,
+			target:     "/foo/bar",
+			wantStatus: http.StatusOK,
+			wantBody:   "GET /bar  /foo/bar ",
+		},
+		{
+			method:     http.MethodPost,
+			target:     "/foo/bar/baz",
+			wantStatus: http.StatusOK,
+			wantBody:   "POST /bar/baz  /foo/bar/baz ",
+		},
+		{
+			method:     "FOO",
+			target:     "/foo/",
+			wantStatus: http.StatusOK,
+			wantBody:   "FOO /  /foo/ ",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/foo/a%2Fb/c%20d",
+			wantStatus: http.StatusOK,
+			wantBody:   "GET /a/b/c d /a%2Fb/c%20d /foo/a/b/c d ",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/foo",
+			wantStatus: http.StatusMovedPermanently,
+			wantBody: "<a href=\"/foo/\">Moved Permanently</a>." +
+				"\n\n",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/t/acme/api/bar",
+			header:     http.Header{"X-Foo": []string{"bar"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "GET /bar  /t/acme/api/bar acme",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/t/acme/api/bar",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Not Found\n",
+		},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		for k, vs := range tt.header {
+			req.Header[k] = vs
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		recr := rec.Result()
+		recrb, _ := ioutil.ReadAll(recr.Body)
+		if got, want := recr.StatusCode, tt.wantStatus; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		} else if got, want := string(recrb), tt.wantBody; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	ir := &Router{}
+	ir.Handle(http.MethodGet, "/baz/*", h)
+
+	r = &Router{}
+	r.Mount("/foo", http.StripPrefix("/bar", ir))
+
+	req := httptest.NewRequest(http.MethodGet, "/foo/bar/baz/qux", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	recr := rec.Result()
+	recrb, _ := ioutil.ReadAll(recr.Body)
+	if got, want := recr.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := string(recrb),
+		"GET /baz/qux  /foo/bar/baz/qux "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	om := &Router{}
+	om.Mount("/foo", r)
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/foo/bar/baz/qux", nil)
+	rec = httptest.NewRecorder()
+	om.ServeHTTP(rec, req)
+	recr = rec.Result()
+	recrb, _ = ioutil.ReadAll(recr.Body)
+	if got, want := recr.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := string(recrb),
+		"GET /baz/qux  /foo/foo/bar/baz/qux "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	ir = &Router{}
+	ir.Handle(http.MethodGet, "/users/:id", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		rw.Write([]byte(PathParam(req, "id") + " " + RoutePattern(req)))
+	}))
+
+	r = &Router{}
+	r.Mount("/t/:tenant", ir, MiddlewareFunc(func(
+		next http.Handler,
+	) http.Handler {
+		return http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			next.ServeHTTP(rw, req)
+			rw.Write([]byte(" " + PathParam(req, "tenant") + " " +
+				RoutePattern(req)))
+		})
+	}))
+
+	for _, ctx := range []context.Context{
+		context.Background(),
+		Context(),
+	} {
+		req = httptest.NewRequest(
+			http.MethodGet,
+			"/t/acme/users/1",
+			nil,
+		)
+		req = req.WithContext(ctx)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got, want := rec.Body.String(),
+			"1 /users/:id acme /t/:tenant/*"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRouterAttach(t *testing.T) {
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.Method + " " + PathParam(req, "tenant") +
+			" " + PathParam(req, "id") + " " + RoutePattern(req)))
+	})
+
+	tag := func(s string) Middleware {
+		return MiddlewareFunc(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(
+				rw http.ResponseWriter,
+				req *http.Request,
+			) {
+				rw.Write([]byte(s))
+				next.ServeHTTP(rw, req)
+			})
+		})
+	}
+
+	lib := &Router{PathPrefix: "/v1", Middlewares: []Middleware{tag("L")}}
+	lib.Handle(http.MethodGet, "/users/:id", h, RouteName("user"))
+	lib.Handle(http.MethodDelete, "/users/:id", h, tag("D"))
+	lib.Handle(http.MethodGet, "/files/*", h)
+	lib.Sub("/admin", tag("S")).Handle(http.MethodPost, "", h)
+	(&Router{Parent: lib, Host: "admin.example.com"}).Handle(
+		http.MethodGet,
+		"/",
+		h,
+	)
+	lib.Remove(http.MethodGet, "/files/*")
+
+	r := &Router{Middlewares: []Middleware{tag("R")}, Concurrent: true}
+	r.Handle(http.MethodGet, "/", h)
+	r.Sub("/t/:tenant", tag("T")).Attach("/lib", lib, tag("A"))
+
+	if got, want := len(r.Routes()), 5; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := len(lib.Routes()), 4; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	if u, err := r.URL("user", "tenant", "acme", "id", "1"); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := u, "/t/acme/lib/v1/users/1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, tt := range []struct {
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			method:     http.MethodGet,
+			target:     "/t/acme/lib/v1/users/1",
+			wantStatus: http.StatusOK,
+			wantBody: "RTALGET acme 1 " +
+				"/t/:tenant/lib/v1/users/:id",
+		},
+		{
+			method:     http.MethodDelete,
+			target:     "/t/acme/lib/v1/users/1",
+			wantStatus: http.StatusOK,
+			wantBody: "RTALDDELETE acme 1 " +
+				"/t/:tenant/lib/v1/users/:id",
+		},
+		{
+			method:     http.MethodPost,
+			target:     "/t/acme/lib/v1/admin",
+			wantStatus: http.StatusOK,
+			wantBody:   "RTALSPOST acme  /t/:tenant/lib/v1/admin",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "http://admin.example.com/t/acme/lib/v1/",
+			wantStatus: http.StatusOK,
+			wantBody:   "RTALGET acme  /t/:tenant/lib/v1/",
+		},
+		{
+			method:     http.MethodGet,
+			target:     "/",
+			wantStatus: http.StatusOK,
+			wantBody:   "RGET   /",
+		},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req.WithContext(Context()))
+		recr := rec.Result()
+		recrb, _ := ioutil.ReadAll(recr.Body)
+		if got, want := recr.StatusCode, tt.wantStatus; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		} else if got, want := string(recrb), tt.wantBody; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	lib = &Router{}
+	lib.Handle(http.MethodGet, "/foo/*", h)
+	lib.Handle(http.MethodGet, "/bar", h)
+
+	r = &Router{}
+	r.Attach("/lib", lib)
+	if got, want := r.Match(
+		http.MethodGet,
+		"/lib/foo",
+	).Status, MatchTSR; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := len(r.Routes()), 3; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	r = &Router{}
+	r.Handle(http.MethodGet, "/lib/bar", h)
+	err := r.TryAttach("/lib", lib)
+	if err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrRouteAlreadyExists) {
+		t.Errorf("got %q, want %q", err, ErrRouteAlreadyExists)
+	} else if got, want := err.Error(), `r2: cannot register route `+
+		`"/lib/bar" for method "GET": `+
+		`route already exists`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := len(r.Routes()), 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/lib/foo/baz",
+	).Status, MatchNotFound; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	if err := r.TryAttach("/lib2", lib); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := len(r.Routes()), 4; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	for _, tt := range []struct {
+		r          *Router
+		sr         *Router
+		wantErr    error
+		wantDetail string
+	}{
+		{r, lib, ErrRouteAlreadyExists, "route already exists"},
+		{
+			r,
+			lib.Sub("/foo"),
+			ErrInvalidAttachedRouter,
+			"attached router cannot have a parent",
+		},
+		{
+			r.Sub("/foo"),
+			r,
+			ErrInvalidAttachedRouter,
+			"router cannot be attached to itself",
+		},
+	} {
+		err := tt.r.TryAttach("/lib", tt.sr)
+		if err == nil {
+			t.Fatal("expected error")
+		} else if !errors.Is(err, tt.wantErr) {
+			t.Errorf("got %q, want %q", err, tt.wantErr)
+		} else if got, want := err.(*RouteError).Detail,
+			tt.wantDetail; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		func() {
+			defer func() {
+				got, _ := recover().(string)
+				if want := err.Error(); got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			}()
+
+			tt.r.Attach("/lib", tt.sr)
+		}()
+	}
+
+	if got, want := len(r.Routes()), 4; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func TestEscapedSuffix(t *testing.T) {
+	for _, tt := range []struct {
+		rawPath string
+		s       string
+		want    string
+	}{
+		{"/foo/a%2Fb", "a/b", "/a%2Fb"},
+		{"/foo/a%2Fb/c", "a/b/c", "/a%2Fb/c"},
+		{"/foo%2Fbar/", "", "/"},
+		{"/foo/%zz/bar", "x/bar", ""},
+		{"/foo/bar", "baz", ""},
+		{"foo", "foo", ""},
+	} {
+		got, want := escapedSuffix(tt.rawPath, tt.s), tt.want
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
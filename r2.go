@@ -5,10 +5,18 @@ package r2
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// ErrPathParamNotFound is the error wrapped in the [PathParamError] when the
+// path parameter to be converted is not found.
+var ErrPathParamNotFound = errors.New("path parameter not found")
+
 // Context returns a non-nil [context.Context] that is never canceled and has no
 // deadline. It is typically used in the [http.Server.BaseContext] to avoid the
 // [Router.Handler] from calling the [http.Request.WithContext], which can
@@ -20,22 +28,140 @@ func Context() context.Context {
 // PathParam returns a path parameter value of the req for the name. It returns
 // empty string if not found.
 func PathParam(req *http.Request, name string) string {
+	ppv, _ := pathParam(req, name)
+	return ppv
+}
+
+// PathParamInt returns a path parameter value of the req for the name as an
+// int. It returns a [*PathParamError] if not found or the value is not a valid
+// base 10 integer.
+func PathParamInt(req *http.Request, name string) (int, error) {
+	ppv, ok := pathParam(req, name)
+	if !ok {
+		return 0, &PathParamError{
+			Name: name,
+			Type: "int",
+			Err:  ErrPathParamNotFound,
+		}
+	}
+
+	i, err := strconv.ParseInt(ppv, 10, 0)
+	if err != nil {
+		return 0, &PathParamError{
+			Name:  name,
+			Value: ppv,
+			Type:  "int",
+			Err:   err.(*strconv.NumError).Err,
+		}
+	}
+
+	return int(i), nil
+}
+
+// PathParamUint returns a path parameter value of the req for the name as an
+// uint. It returns a [*PathParamError] if not found or the value is not a valid
+// base 10 unsigned integer.
+func PathParamUint(req *http.Request, name string) (uint, error) {
+	ppv, ok := pathParam(req, name)
+	if !ok {
+		return 0, &PathParamError{
+			Name: name,
+			Type: "uint",
+			Err:  ErrPathParamNotFound,
+		}
+	}
+
+	u, err := strconv.ParseUint(ppv, 10, 0)
+	if err != nil {
+		return 0, &PathParamError{
+			Name:  name,
+			Value: ppv,
+			Type:  "uint",
+			Err:   err.(*strconv.NumError).Err,
+		}
+	}
+
+	return uint(u), nil
+}
+
+// PathParamBool returns a path parameter value of the req for the name as a
+// bool. It returns a [*PathParamError] if not found or the value is not one of
+// the values accepted by the [strconv.ParseBool].
+func PathParamBool(req *http.Request, name string) (bool, error) {
+	ppv, ok := pathParam(req, name)
+	if !ok {
+		return false, &PathParamError{
+			Name: name,
+			Type: "bool",
+			Err:  ErrPathParamNotFound,
+		}
+	}
+
+	b, err := strconv.ParseBool(ppv)
+	if err != nil {
+		return false, &PathParamError{
+			Name:  name,
+			Value: ppv,
+			Type:  "bool",
+			Err:   err.(*strconv.NumError).Err,
+		}
+	}
+
+	return b, nil
+}
+
+// PathParamUUID returns a path parameter value of the req for the name as a
+// UUID. It returns a [*PathParamError] if not found or the value is not a UUID
+// in the canonical textual representation, such as
+// "123e4567-e89b-12d3-a456-426614174000".
+func PathParamUUID(req *http.Request, name string) ([16]byte, error) {
+	ppv, ok := pathParam(req, name)
+	if !ok {
+		return [16]byte{}, &PathParamError{
+			Name: name,
+			Type: "uuid",
+			Err:  ErrPathParamNotFound,
+		}
+	}
+
+	uuid, err := parseUUID(ppv)
+	if err != nil {
+		return [16]byte{}, &PathParamError{
+			Name:  name,
+			Value: ppv,
+			Type:  "uuid",
+			Err:   err,
+		}
+	}
+
+	return uuid, nil
+}
+
+// pathParam returns a path parameter value of the req for the name along with
+// whether it is found.
+func pathParam(req *http.Request, name string) (string, bool) {
 	d, ok := req.Context().Value(dataContextKey).(*data)
 	if !ok {
-		return ""
+		return "", false
 	}
 
 	for i, ppn := range d.pathParamNames {
-		if ppn == name {
-			return d.pathParamValues[i]
+		if pathParamNameMatches(ppn, name) {
+			return d.pathParamValues[i], true
 		}
 	}
 
-	return ""
+	return "", false
+}
+
+// pathParamNameMatches reports whether the name refers to the path parameter
+// declared as the ppn.
+func pathParamNameMatches(ppn, name string) bool {
+	return ppn == name || ppn[0] == '*' && (name == "*" || ppn[1:] == name)
 }
 
 // PathParamNames returns path parameter names of the req. It returns nil if not
-// found.
+// found. The name of a wildcard path parameter always starts with '*'.
 func PathParamNames(req *http.Request) []string {
 	d, ok := req.Context().Value(dataContextKey).(*data)
 	if !ok {
@@ -56,6 +182,134 @@ func PathParamValues(req *http.Request) []string {
 	return d.pathParamValues[:len(d.pathParamNames)]
 }
 
+// HostParam returns a host parameter value of the req for the name. It returns
+// empty string if not found. See the [Router.Host] for more details.
+func HostParam(req *http.Request, name string) string {
+	d, ok := req.Context().Value(dataContextKey).(*data)
+	if !ok {
+		return ""
+	}
+
+	for i, hpn := range d.hostParamNames {
+		if hpn == name {
+			return d.hostParamValues[i]
+		}
+	}
+
+	return ""
+}
+
+// AllowedMethods returns the methods allowed for the path of the req. It is
+// only available in the [Router.MethodNotAllowedHandler] and the automatic
+// OPTIONS responses (see the [Router.AutoOPTIONS]). It returns nil if not
+// found.
+func AllowedMethods(req *http.Request) []string {
+	d, ok := req.Context().Value(dataContextKey).(*data)
+	if !ok {
+		return nil
+	}
+
+	return d.allowedMethods
+}
+
+// RoutePattern returns the path of the route matched for the req, in which each
+// path parameter is written as it is declared in the [Router.Handle], such as
+// "/users/:id". It returns empty string if not found.
+//
+// Unlike the path of the req, it is suitable for labeling metrics and traces.
+//
+// For routes without any path or host parameters, it is only available when
+// the req is derived from the [Context], since the [Router.Handler] does not
+// attach any data to the req for them otherwise.
+func RoutePattern(req *http.Request) string {
+	d, ok := req.Context().Value(dataContextKey).(*data)
+	if !ok || d.routeNode == nil {
+		return ""
+	}
+
+	return d.routeNode.pattern(d.routeMethod)
+}
+
+// RouteMethod returns the method of the route matched for the req. It returns
+// empty string if not found, or if the route is a catch-all one (see the
+// [Router.Handle]).
+//
+// Note that it is "GET" for HEAD requests served by GET routes (see the
+// [Router.AutoHEAD]), and "OPTIONS" for the automatic OPTIONS responses (see
+// the [Router.AutoOPTIONS]). Like the [RoutePattern], it is only available
+// for routes without any path or host parameters when the req is derived from
+// the [Context].
+func RouteMethod(req *http.Request) string {
+	d, ok := req.Context().Value(dataContextKey).(*data)
+	if !ok {
+		return ""
+	}
+
+	return d.routeMethod
+}
+
+// OriginalPath returns the path of the req before it is rewritten by the
+// handlers registered by the [Router.Mount]. When such handlers are nested, the
+// path before the outermost rewrite is returned. It returns the path of the req
+// if the req has never been rewritten.
+func OriginalPath(req *http.Request) string {
+	if p, ok := req.Context().Value(originalPathContextKey).(string); ok {
+		return p
+	}
+
+	return req.URL.Path
+}
+
+// PathParamError is the error returned by the typed path parameter getters,
+// such as the [PathParamInt], when they fail to convert a path parameter.
+type PathParamError struct {
+	// Name is the name of the path parameter.
+	Name string
+
+	// Value is the value of the path parameter.
+	Value string
+
+	// Type is the name of the type that the path parameter is converted
+	// to.
+	Type string
+
+	// Err is the reason that the conversion failed.
+	Err error
+}
+
+// Error implements the [error].
+func (ppe *PathParamError) Error() string {
+	return "r2: cannot convert path parameter " + strconv.Quote(ppe.Name) +
+		" to " + ppe.Type + ": " + ppe.Err.Error()
+}
+
+// Unwrap returns the ppe.Err.
+func (ppe *PathParamError) Unwrap() error {
+	return ppe.Err
+}
+
+// parseUUID parses the s as a UUID in the canonical textual representation.
+func parseUUID(s string) ([16]byte, error) {
+	var uuid [16]byte
+	if len(s) != 36 ||
+		s[8] != '-' ||
+		s[13] != '-' ||
+		s[18] != '-' ||
+		s[23] != '-' {
+		return uuid, strconv.ErrSyntax
+	}
+
+	b, err := hex.DecodeString(s[:8] + s[9:13] + s[14:18] + s[19:23] +
+		s[24:])
+	if err != nil {
+		return uuid, strconv.ErrSyntax
+	}
+
+	copy(uuid[:], b)
+
+	return uuid, nil
+}
+
 // dataContext is a [context.Context] that wraps a [data].
 type dataContext struct {
 	d *data
@@ -85,10 +339,38 @@ type contextKey uint8
 // The context keys.
 const (
 	dataContextKey contextKey = iota
+	originalPathContextKey
 )
 
 // data is a request-scoped data set.
 type data struct {
-	pathParamNames  []string
-	pathParamValues []string
+	pathParamNames      []string
+	pathParamValues     []string
+	pathParamValuesPool *sync.Pool
+	hostParamNames      []string
+	hostParamValues     []string
+	allowedMethods      []string
+	routeNode           *routeNode
+	routeMethod         string
+}
+
+// reset resets the d to its zero value for another request.
+func (d *data) reset() {
+	*d = data{}
+}
+
+// requestData returns the data of the req. If the req has no data, a new one is
+// created and attached to the returned revision of the req.
+func requestData(req *http.Request) (*http.Request, *data) {
+	d, ok := req.Context().Value(dataContextKey).(*data)
+	if !ok {
+		d = &data{}
+		req = req.WithContext(context.WithValue(
+			req.Context(),
+			dataContextKey,
+			d,
+		))
+	}
+
+	return req, d
 }
@@ -2,8 +2,10 @@ package r2
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 )
 
@@ -34,8 +36,8 @@ func TestPathParam(t *testing.T) {
 	}
 
 	d := &data{
-		pathParamNames:  []string{"foo", "bar"},
-		pathParamValues: []string{"bar", "foo"},
+		pathParamNames:  []string{"foo", "bar", "*baz"},
+		pathParamValues: []string{"bar", "foo", "qux"},
 	}
 
 	req = req.WithContext(context.WithValue(
@@ -47,11 +49,140 @@ func TestPathParam(t *testing.T) {
 		t.Errorf("got %s, want %s", got, want)
 	} else if got, want := PathParam(req, "bar"), "foo"; got != want {
 		t.Errorf("got %s, want %s", got, want)
+	} else if got, want := PathParam(req, "baz"), "qux"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	} else if got, want := PathParam(req, "*"), "qux"; got != want {
+		t.Errorf("got %s, want %s", got, want)
 	} else if got, want := PathParam(req, "foobar"), ""; got != want {
 		t.Errorf("got %s, want %s", got, want)
 	}
 }
 
+func TestPathParamInt(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := PathParamInt(req, "foo"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrPathParamNotFound) {
+		t.Errorf("got %q, want %q", err, ErrPathParamNotFound)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		&data{
+			pathParamNames:  []string{"foo", "bar"},
+			pathParamValues: []string{"-123", "bar"},
+		},
+	))
+	if got, err := PathParamInt(req, "foo"); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := -123; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	if _, err := PathParamInt(req, "bar"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got %q, want %q", err, strconv.ErrSyntax)
+	}
+}
+
+func TestPathParamUint(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := PathParamUint(req, "foo"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrPathParamNotFound) {
+		t.Errorf("got %q, want %q", err, ErrPathParamNotFound)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		&data{
+			pathParamNames:  []string{"foo", "bar"},
+			pathParamValues: []string{"123", "-123"},
+		},
+	))
+	if got, err := PathParamUint(req, "foo"); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := uint(123); got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	if _, err := PathParamUint(req, "bar"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got %q, want %q", err, strconv.ErrSyntax)
+	}
+}
+
+func TestPathParamBool(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := PathParamBool(req, "foo"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrPathParamNotFound) {
+		t.Errorf("got %q, want %q", err, ErrPathParamNotFound)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		&data{
+			pathParamNames:  []string{"foo", "bar"},
+			pathParamValues: []string{"true", "yes"},
+		},
+	))
+	if got, err := PathParamBool(req, "foo"); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if !got {
+		t.Error("want true")
+	}
+
+	if _, err := PathParamBool(req, "bar"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got %q, want %q", err, strconv.ErrSyntax)
+	}
+}
+
+func TestPathParamUUID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := PathParamUUID(req, "foo"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrPathParamNotFound) {
+		t.Errorf("got %q, want %q", err, ErrPathParamNotFound)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		&data{
+			pathParamNames: []string{"foo", "bar"},
+			pathParamValues: []string{
+				"123e4567-e89b-12d3-a456-426614174000",
+				"123e4567e89b12d3a456426614174000",
+			},
+		},
+	))
+	if got, err := PathParamUUID(req, "foo"); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := [16]byte{
+		0x12, 0x3e, 0x45, 0x67,
+		0xe8, 0x9b,
+		0x12, 0xd3,
+		0xa4, 0x56,
+		0x42, 0x66, 0x14, 0x17, 0x40, 0x00,
+	}; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := PathParamUUID(req, "bar"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got %q, want %q", err, strconv.ErrSyntax)
+	}
+}
+
 func TestPathParamNames(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	if ppns := PathParamNames(req); ppns != nil {
@@ -114,6 +245,142 @@ func TestParamValues(t *testing.T) {
 	}
 }
 
+func TestHostParam(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, want := HostParam(req, "foo"), ""; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	d := &data{
+		hostParamNames:  []string{"foo", "bar"},
+		hostParamValues: []string{"bar", "foo"},
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		d,
+	))
+	if got, want := HostParam(req, "foo"), "bar"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	} else if got, want := HostParam(req, "bar"), "foo"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	} else if got, want := HostParam(req, "foobar"), ""; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := AllowedMethods(req); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		&data{allowedMethods: []string{"GET", "POST"}},
+	))
+	if ams := AllowedMethods(req); len(ams) != 2 {
+		t.Errorf("got %d, want %d", len(ams), 2)
+	} else if got, want := ams[0], "GET"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	} else if got, want := ams[1], "POST"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, want := RoutePattern(req), ""; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		&data{},
+	))
+	if got, want := RoutePattern(req), ""; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	r := &Router{}
+	r.Handle(http.MethodGet, "/foo/:bar", http.NotFoundHandler())
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	_, req = r.Handler(req)
+	if got, want := RoutePattern(req), "/foo/:bar"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRouteMethod(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, want := RouteMethod(req), ""; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		dataContextKey,
+		&data{routeMethod: http.MethodGet},
+	))
+	if got, want := RouteMethod(req), http.MethodGet; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestOriginalPath(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	if got, want := OriginalPath(req), "/foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = req.WithContext(context.WithValue(
+		context.Background(),
+		originalPathContextKey,
+		"/bar/foo",
+	))
+	if got, want := OriginalPath(req), "/bar/foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPathParamError(t *testing.T) {
+	ppe := &PathParamError{
+		Name:  "foo",
+		Value: "bar",
+		Type:  "int",
+		Err:   strconv.ErrSyntax,
+	}
+	if got, want := ppe.Error(), `r2: cannot convert path parameter "foo" `+
+		`to int: invalid syntax`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := ppe.Unwrap(), strconv.ErrSyntax; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseUUID(t *testing.T) {
+	uuid, err := parseUUID("123e4567-e89b-12d3-a456-426614174000")
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := uuid[15], byte(0x00); got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	for _, s := range []string{
+		"123e4567-e89b-12d3-a456-42661417400",
+		"123e4567_e89b_12d3_a456_426614174000",
+		"123e4567-e89b-12d3-a456-42661417400g",
+	} {
+		if _, err := parseUUID(s); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
 func TestDataContext(t *testing.T) {
 	dc := &dataContext{d: &data{}}
 	if deadline, ok := dc.Deadline(); !deadline.IsZero() {
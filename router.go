@@ -1,17 +1,22 @@
 package r2
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"net/url"
 	stdpath "path"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // Router is a registry of all registered routes for HTTP request routing.
 //
 // Make sure that all fields of the Router have been finalized before calling
-// any of its methods.
+// any of its methods. Routes must not be registered or removed while the
+// Router is being used unless the [Router.Concurrent] is true.
 type Router struct {
 	// Parent is the parent [Router].
 	Parent *Router
@@ -19,11 +24,28 @@ type Router struct {
 	// PathPrefix is the path prefix of all routes to be registered.
 	PathPrefix string
 
+	// Host is the host of all routes to be registered. If it is empty, the
+	// Host of the [Router.Parent] is used.
+	//
+	// A label of the Host can be a name (consisting of letters, digits and
+	// '_') wrapped in "{}", such as "{tenant}.example.com", to declare a
+	// host parameter that matches any single non-empty label of the host of
+	// a request. The [HostParam] can be used to get those declared host
+	// parameters after a request is matched.
+	//
+	// Hosts without host parameters take precedence over the others, which
+	// are tried in the order they are first used. Routes registered without
+	// a Host are only used for requests whose hosts match none of the Hosts
+	// used by the registered routes. The host of a request is matched
+	// case-insensitively and without its port.
+	Host string
+
 	// Middlewares is the [Middleware] chain that performs after routing.
 	Middlewares []Middleware
 
 	// NotFoundHandler writes not found responses. It is used when the
-	// [Router.Handler] fails to find a matching handler for a request.
+	// [Router.Handler] fails to find a matching handler for a request,
+	// unless a SPA fallback serves the request (see the [Router.ServeSPA]).
 	//
 	// If the NotFoundHandler is nil, a default one is used.
 	//
@@ -33,14 +55,37 @@ type Router struct {
 
 	// MethodNotAllowedHandler writes method not allowed responses. It is
 	// used when the [Router.Handler] finds a handler that matches only the
-	// path but not the method for a request.
+	// path but not the method for a request. The [AllowedMethods] can be
+	// used to get the methods allowed for the path of the request.
 	//
-	// If the MethodNotAllowedHandler is nil, a default one is used.
+	// If the MethodNotAllowedHandler is nil, a default one is used, which
+	// sets the Allow header to the result of the [AllowedMethods].
 	//
 	// Note that the MethodNotAllowedHandler will be ignored when the
 	// [Router.Parent] is not nil.
 	MethodNotAllowedHandler http.Handler
 
+	// NotAcceptableHandler writes not acceptable responses. It is used when
+	// the [Router.Handler] finds routes that match a request except for
+	// their [Produces]. See the [Router.Handle] for more details.
+	//
+	// If the NotAcceptableHandler is nil, a default one is used.
+	//
+	// Note that the NotAcceptableHandler will be ignored when the
+	// [Router.Parent] is not nil.
+	NotAcceptableHandler http.Handler
+
+	// UnsupportedMediaTypeHandler writes unsupported media type responses.
+	// It is used when the [Router.Handler] finds routes that match a
+	// request except for their [Consumes]. See the [Router.Handle] for
+	// more details.
+	//
+	// If the UnsupportedMediaTypeHandler is nil, a default one is used.
+	//
+	// Note that the UnsupportedMediaTypeHandler will be ignored when the
+	// [Router.Parent] is not nil.
+	UnsupportedMediaTypeHandler http.Handler
+
 	// TSRHandler writes TSR (Trailing Slash Redirect) responses. It may be
 	// used when the path of a registered route ends with "/*", and the
 	// [Router.Handler] fails to find a matching handler for a request whose
@@ -53,13 +98,47 @@ type Router struct {
 	// not nil.
 	TSRHandler http.Handler
 
-	routeTree                      *routeNode
-	registeredRoutes               map[string]bool
-	maxPathParams                  int
-	pathParamValuesPool            sync.Pool
-	chainedNotFoundHandler         http.Handler
-	chainedMethodNotAllowedHandler http.Handler
-	chainedTSRHandler              http.Handler
+	// AutoHEAD indicates whether the [Router.Handler] should use the GET
+	// handler of a route to serve HEAD requests when the route has neither
+	// a HEAD handler nor a catch-all one. The GET handler is served with an
+	// [http.ResponseWriter] that discards the response body but keeps the
+	// headers, and sets the Content-Length header to the length of the
+	// discarded body if it is not set.
+	//
+	// Note that the AutoHEAD will be ignored when the [Router.Parent] is
+	// not nil.
+	AutoHEAD bool
+
+	// AutoOPTIONS indicates whether the [Router.Handler] should
+	// automatically respond to OPTIONS requests for routes without OPTIONS
+	// handlers. The automatic OPTIONS responses have no content, with the
+	// Allow header set to the result of the [AllowedMethods].
+	//
+	// Note that the AutoOPTIONS will be ignored when the [Router.Parent] is
+	// not nil.
+	AutoOPTIONS bool
+
+	// Concurrent indicates whether routes can be registered and removed
+	// while the Router is being used, such as after the [http.Server] has
+	// started serving. When it is true, each registration or removal works
+	// on a copy of the registered routes, which then atomically replaces
+	// the current one, so that the [Router.Handler] is never blocked. The
+	// cost is that each registration or removal takes time proportional to
+	// the number of registered routes.
+	//
+	// Note that the Concurrent will be ignored when the [Router.Parent] is
+	// not nil.
+	Concurrent bool
+
+	table                              atomic.Value
+	tableMutex                         sync.Mutex
+	chainHandlersOnce                  sync.Once
+	chainedNotFoundHandler             http.Handler
+	chainedMethodNotAllowedHandler     http.Handler
+	chainedNotAcceptableHandler        http.Handler
+	chainedUnsupportedMediaTypeHandler http.Handler
+	chainedTSRHandler                  http.Handler
+	chainedAutoOPTIONSHandler          http.Handler
 }
 
 // Sub returns a new instance of the [Router] inherited from the r with the
@@ -75,834 +154,3279 @@ func (r *Router) Sub(pathPrefix string, ms ...Middleware) *Router {
 // Handle registers a new route for the method (empty string means catch-all)
 // and path with the matching h and optional ms.
 //
-// A ':' followed by a name in the path declares a path parameter that matches
-// all characters except '/'. And an '*' in the path declares a wildcard path
-// parameter that greedily matches all characters, with "*" as its name. The
-// [PathParam] can be used to get those declared path parameters after a request
-// is matched.
+// A ':' followed by a name (consisting of letters, digits and '_') in the path
+// declares a path parameter that matches all characters except '/'. And an '*'
+// in the path declares a wildcard path parameter that greedily matches all
+// characters, with "*" as its name. An '*' can also be followed by a name, such
+// as "*filepath", to declare a named wildcard path parameter, whose name is
+// "*filepath" and which can be got by either "filepath" or "*". The [PathParam]
+// can be used to get those declared path parameters after a request is matched.
+// Routes for different methods can declare different names for the same path
+// parameters, such as "/users/:id" for GET and "/users/:uid" for DELETE, but
+// routes that share the same method and path (see the [Matcher] below) must
+// declare the same names.
+//
+// A path element can contain multiple path parameters as long as they are
+// separated by static characters, such as ":name.:ext" and ":year-:month". A
+// path parameter followed by static characters in the same path element stops
+// at the first occurrence of those static characters that allows the rest of
+// the path to match. Such a path parameter must also have a type or a
+// constraint (see below) if it is the only one in its path element, such as
+// ":id:int.png", since its name would otherwise be ambiguous with the one used
+// to run to the end of the path element, such as ":user-id".
+//
+// An '*' can also appear in the middle of the path as long as it is followed by
+// a '/', such as "/repos/*/blob/:ref". Such a wildcard path parameter matches
+// as many path elements as possible (at least one) that allow the rest of the
+// path to match.
+//
+// The name of a path parameter can be followed by a regular expression wrapped
+// in "<>" to constrain it, such as ":id<[0-9]+>". A constrained path parameter
+// only matches values that are fully matched by its regular expression, and
+// other routes will be tried when it does not. Constrained path parameters take
+// precedence over the unconstrained one at the same position.
+//
+// Instead of a regular expression, the name of a path parameter can also be
+// followed by a ':' and a type to constrain it, such as ":id:int". The
+// supported types are "int", "uint", "bool" and "uuid", and the values of such
+// path parameters can be got by the [PathParamInt], [PathParamUint],
+// [PathParamBool] and [PathParamUUID] without any conversion errors.
+//
+// A part of the path wrapped in "()" is optional, such as "/archive(/:year)",
+// and optional parts can be nested, such as "/archive(/:year(/:month))". Such a
+// path is registered as all the paths it represents by including or omitting
+// each of its optional parts. A path parameter that starts a path element can
+// also be followed by a '?' to make the path element optional, such as
+// "/posts/:page?", which is the same as "/posts(/:page)". The [PathParam]
+// returns empty string for path parameters that are absent from the matched
+// path.
+//
+// Any of the ms (and the r.Middlewares) can be a [Matcher] to make the route
+// only match requests that are matched by all of its Matchers. Routes with
+// Matchers can share the same method and path with each other and with at most
+// one route without any Matchers. The routes with Matchers are tried in the
+// order they are registered, except that the [Produces] prefers routes whose
+// declared media types are more acceptable, and the route without any Matchers
+// is only tried after all of them. When none of them matches a request, the
+// [Router.Handler] keeps looking for other routes, and treats the request as
+// not found (or not acceptable or unsupported media type when the [Produces] or
+// the [Consumes] is the reason) if there is none.
 //
-// When the path ends with "/*", and there is at least one path element before
-// it without any other path parameters, a sepcial catch-all route will be
-// automatically registered with the result of path[:len(path)-2] as its path
-// and the r.TSRHandler as its handler. This special catch-all route will be
-// overridden if a route with such path is explicitly registered, regardless of
-// its method.
+// Any of the ms can be the result of the [RouteName] to name the route, so that
+// the [Router.URL] can be used to build its path.
+//
+// When the path ends with "/*" (or a named one like "/*filepath"), and there is
+// at least one path element before it without any other path parameters, a
+// sepcial catch-all route will be automatically registered with the part of the
+// path before the "/*" as its path and the r.TSRHandler as its handler. This
+// special catch-all route will be overridden if a route with such path is
+// explicitly registered, regardless of its method.
+//
+// It panics if the route cannot be registered. Use the [Router.TryHandle] to
+// get an error instead.
 func (r *Router) Handle(method, path string, h http.Handler, ms ...Middleware) {
+	if err := r.handleHost(r.Host, method, path, h, ms); err != nil {
+		panic("r2: " + err.Detail)
+	}
+}
+
+// TryHandle is like the [Router.Handle], but returns a [*RouteError] instead of
+// panicking when the route cannot be registered, in which case the r is left
+// unchanged. It is useful for registering routes that come from configuration
+// files or plugins at runtime.
+func (r *Router) TryHandle(
+	method string,
+	path string,
+	h http.Handler,
+	ms ...Middleware,
+) error {
+	if err := r.handleHost(r.Host, method, path, h, ms); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// handleHost is like the [Router.TryHandle], but registers the route for the
+// host instead of the r.Host.
+func (r *Router) handleHost(
+	host string,
+	method string,
+	path string,
+	h http.Handler,
+	ms []Middleware,
+) *RouteError {
 	if r.Parent != nil {
-		r.Parent.Handle(
+		if host == "" {
+			host = r.Parent.Host
+		}
+
+		return r.Parent.handleHost(
+			host,
 			method,
 			r.PathPrefix+path,
 			h,
-			append(r.Middlewares, ms...)...,
+			append(r.Middlewares, ms...),
 		)
-		return
 	}
 
-	if r.routeTree == nil {
-		r.routeTree = &routeNode{
-			staticChildren: make([]*routeNode, 255),
-			methodHandlers: &methodHandlers{},
-		}
+	path = r.PathPrefix + path
+	if err := r.register(host, method, path, h, ms); err != nil {
+		err.Method, err.Path = method, path
+		return err
+	}
+
+	return nil
+}
 
-		r.registeredRoutes = map[string]bool{}
-		r.notFoundHandler()
-		r.methodNotAllowedHandler()
-		r.tsrHandler()
+// register registers the route for the host, method and path with the
+// matching h and ms. It returns a [*RouteError] without changing the r if the
+// route cannot be registered.
+func (r *Router) register(
+	host string,
+	method string,
+	path string,
+	h http.Handler,
+	ms []Middleware,
+) *RouteError {
+	r.tableMutex.Lock()
+	defer r.tableMutex.Unlock()
+
+	rr, err := r.prepareRoute(r.loadTable(), host, method, path, h, ms)
+	if err != nil {
+		return err
 	}
 
-	for _, c := range method {
-		if (c < '0' || c > '9') &&
-			(c < 'A' || c > 'Z') &&
-			(c < 'a' || c > 'z') {
-			panic("r2: route method must be alphanumeric")
+	tb := r.writableTable()
+	r.commitRoute(tb, rr)
+	r.table.Store(tb)
+
+	return nil
+}
+
+// routeRegistration is a route validated by the [Router.prepareRoute] and to
+// be registered by the [Router.commitRoute].
+type routeRegistration struct {
+	route Route
+	hrt   *hostRouteTree
+	rps   []*routePath
+	h     http.Handler
+	ms    []Middleware
+}
+
+// prepareRoute validates the route for the host, method and path with the
+// matching h and ms against the tb without changing it. It must be called with
+// the r.tableMutex held.
+func (r *Router) prepareRoute(
+	tb *routeTable,
+	host string,
+	method string,
+	path string,
+	h http.Handler,
+	ms []Middleware,
+) (*routeRegistration, *RouteError) {
+	if !isRouteMethod(method) {
+		return nil, &RouteError{
+			Err:    ErrInvalidRouteMethod,
+			Detail: "route method must be alphanumeric",
 		}
 	}
 
-	path = r.PathPrefix + path
 	if path == "" {
-		panic("r2: route path cannot be empty")
+		return nil, &RouteError{
+			Err:    ErrInvalidRoutePath,
+			Detail: "route path cannot be empty",
+		}
 	} else if path[0] != '/' {
-		panic("r2: route path must start with '/'")
+		return nil, &RouteError{
+			Err:    ErrInvalidRoutePath,
+			Detail: "route path must start with '/'",
+		}
 	}
 
-	hasTrailingSlash := path[len(path)-1] == '/'
-	path = stdpath.Clean(path)
-	if hasTrailingSlash && path != "/" {
-		path += "/"
+	var hrt *hostRouteTree
+
+	host = strings.ToLower(host)
+	if host != "" && tb.hostRouteTree(host) == nil {
+		var err *RouteError
+		if hrt, err = newHostRouteTree(host); err != nil {
+			return nil, err
+		}
 	}
 
-	var hasAtLeastOnePathParam bool
-	if strings.Contains(path, ":") {
-		hasAtLeastOnePathParam = true
-		for _, p := range strings.Split(path, "/") {
-			if strings.Count(p, ":") > 1 {
-				panic("r2: only one ':' is allowed in a " +
-					"route path element")
-			}
+	var name string
+	for _, m := range ms {
+		if rnm, ok := m.(routeNameMiddleware); ok {
+			name = string(rnm)
 		}
 	}
 
-	if strings.Contains(path, "*") {
-		hasAtLeastOnePathParam = true
+	if name != "" && tb.namedRoutes[name] != nil {
+		return nil, &RouteError{
+			Err:    ErrRouteNameAlreadyExists,
+			Detail: "route name already exists",
+		}
+	}
 
-		if strings.Count(path, "*") > 1 {
-			panic("r2: only one '*' is allowed in a route path")
+	var (
+		route = Route{
+			Host:        host,
+			Method:      method,
+			Pattern:     path,
+			Name:        name,
+			handler:     h,
+			middlewares: ms,
+		}
+		hasMatchers bool
+	)
+	for _, m := range append(r.Middlewares, ms...) {
+		switch m.(type) {
+		case nil, routeNameMiddleware:
+		case Matcher:
+			hasMatchers = true
+		default:
+			route.Middlewares++
 		}
+	}
+
+	rps, err := parseRoutePaths(path)
+	if err != nil {
+		return nil, err
+	}
 
-		if path[len(path)-1] != '*' {
-			panic("r2: '*' can only appear at the end of a route " +
-				"path")
+	for i, rp := range rps {
+		if hasMatchers {
+			break
 		}
 
-		if strings.Contains(path[strings.LastIndex(path, "/"):], ":") {
-			panic("r2: ':' and '*' cannot appear in the same " +
-				"route path element")
+		exists := tb.registeredRoutes[host+" "+method+rp.key]
+		for _, p := range rps[:i] {
+			exists = exists || p.key == rp.key
 		}
-	}
 
-	routeName := method + path
-	for i, l := len(method), len(routeName); i < l; i++ {
-		if routeName[i] == ':' {
-			j := i + 1
-			for ; i < l && routeName[i] != '/'; i++ {
+		if exists {
+			return nil, &RouteError{
+				Err:    ErrRouteAlreadyExists,
+				Detail: "route already exists",
 			}
+		}
+	}
 
-			routeName = routeName[:j] + routeName[i:]
-
-			i, l = j, len(routeName)
+	rt := tb.routeTree
+	if host != "" {
+		rt = nil
+		if hrt := tb.hostRouteTree(host); hrt != nil {
+			rt = hrt.routeTree
 		}
 	}
 
-	if r.registeredRoutes[routeName] {
-		panic("r2: route already exists")
-	} else {
-		r.registeredRoutes[routeName] = true
+	for _, rp := range rps {
+		if rt == nil {
+			break
+		}
+
+		n := rt.node(rp.path, rp.pathParamConstraints)
+		if n == nil || !n.hasHandler(method) {
+			continue
+		}
+
+		for i, ppn := range n.pathParamNamesFor(method) {
+			if ppn != rp.pathParamNames[i] {
+				return nil, &RouteError{
+					Err: ErrConflictingPathParamNames,
+					Detail: "route path parameter names " +
+						"conflict with existing route",
+				}
+			}
+		}
 	}
 
 	if h == nil {
-		panic("r2: route handler cannot be nil")
+		return nil, &RouteError{
+			Err:    ErrNilRouteHandler,
+			Detail: "route handler cannot be nil",
+		}
 	}
 
-	if ms := append(r.Middlewares, ms...); len(ms) > 0 {
-		for i := len(ms) - 1; i >= 0; i-- {
-			if ms[i] != nil {
-				h = ms[i].ChainHTTPHandler(h)
-			}
+	return &routeRegistration{
+		route: route,
+		hrt:   hrt,
+		rps:   rps,
+		h:     h,
+		ms:    ms,
+	}, nil
+}
+
+// commitRoute registers the rr, which must have been validated by the
+// [Router.prepareRoute] against the tb, into the tb. It must be called with the
+// r.tableMutex held.
+func (r *Router) commitRoute(tb *routeTable, rr *routeRegistration) {
+	if tb.routeTree == nil {
+		tb.routeTree = &routeNode{
+			staticChildren: make([]*routeNode, 255),
+			methodHandlers: &methodHandlers{},
 		}
+
+		tb.registeredRoutes = map[string]bool{}
+		tb.namedRoutes = map[string][]*urlPattern{}
+		r.chainHandlers()
 	}
 
-	if hasAtLeastOnePathParam {
-		ph := h
-		h = http.HandlerFunc(func(
-			rw http.ResponseWriter,
-			req *http.Request,
-		) {
-			ph.ServeHTTP(rw, req)
-			d, ok := req.Context().Value(dataContextKey).(*data)
-			if ok {
-				//lint:ignore SA6002 this is harmless
-				r.pathParamValuesPool.Put(d.pathParamValues)
-			}
-		})
+	var (
+		route = rr.route
+		host  = route.Host
+		rt    = tb.routeTree
+	)
+	if host != "" {
+		hrt := tb.hostRouteTree(host)
+		if hrt == nil {
+			hrt = rr.hrt
+			tb.addHostRouteTree(hrt)
+		}
+
+		rt = hrt.routeTree
 	}
 
-	var pathParamNames []string
-	for i, l := 0, len(path); i < l; i++ {
-		switch path[i] {
-		case ':':
-			r.insertRoute(
-				method,
-				path[:i],
-				nil,
-				staticRouteNode,
-				nil,
-			)
+	var tsrRoutes []Route
+	for _, rp := range rr.rps {
+		tsrPath := r.handle(
+			tb,
+			rt,
+			host,
+			route.Name,
+			route.Method,
+			rp,
+			rr.h,
+			rr.ms,
+		)
+		if len(rp.pathParamNames) > len(route.PathParamNames) {
+			route.PathParamNames = rp.pathParamNames
+		}
 
-			j := i + 1
-			for ; i < l && path[i] != '/'; i++ {
-			}
+		if tsrPath != "" {
+			tsrRoutes = append(tsrRoutes, Route{
+				Host:    host,
+				Pattern: tsrPath,
+				TSR:     true,
+			})
+		}
+	}
 
-			pathParamName := path[j:i]
-			if pathParamName == "" {
-				panic("r2: route path parameter name cannot " +
-					"be empty")
+	tb.routes = append(tb.routes, route)
+	tb.routes = append(tb.routes, tsrRoutes...)
+}
+
+// Routes returns all registered routes in the order they are registered. The
+// routes registered through the [Router.Sub] (or any other [Router] with the r
+// as its [Router.Parent]) are included, and each of them is followed by the
+// TSR (Trailing Slash Redirect) route automatically registered for it (if
+// any). See the [Router.Handle] for more details.
+func (r *Router) Routes() []Route {
+	if r.Parent != nil {
+		return r.Parent.Routes()
+	}
+
+	tb := r.loadTable()
+	routes := make([]Route, 0, len(tb.routes))
+	for _, route := range tb.routes {
+		if route.TSR {
+			rt := tb.routeTree
+			if route.Host != "" {
+				rt = tb.hostRouteTree(route.Host).routeTree
 			}
 
-			for _, pn := range pathParamNames {
-				if pn == pathParamName {
-					panic("r2: route path cannot have " +
-						"duplicate parameter names")
-				}
+			// The TSR route may have been overridden, or ignored
+			// because another route with its path was registered
+			// before it.
+			n := rt.node(route.Pattern, nil)
+			if n == nil ||
+				n.catchAllHandler == nil ||
+				n.catchAllHandler.method != "_tsr" {
+				continue
 			}
+		}
 
-			pathParamNames = append(pathParamNames, pathParamName)
-			path = path[:j] + path[i:]
+		route.handler, route.middlewares = nil, nil
+		routes = append(routes, route)
+	}
 
-			i, l = j, len(path)
-			if i < l {
-				r.insertRoute(
-					method,
-					path[:i],
-					nil,
-					paramRouteNode,
-					pathParamNames,
-				)
-			} else {
-				r.insertRoute(
-					method,
-					path[:i],
-					h,
-					paramRouteNode,
-					pathParamNames,
-				)
-			}
-		case '*':
-			r.insertRoute(
-				method,
-				path[:i],
-				nil,
-				staticRouteNode,
-				nil,
-			)
+	return routes
+}
 
-			offset := i - strings.LastIndexByte(path[:i], '/') - 1
-			if offset == 0 && i > 1 && len(pathParamNames) == 0 {
-				method, path := "_tsr", path[:i-1]
-				routeName := method + path
-				if !r.registeredRoutes[routeName] {
-					r.registeredRoutes[routeName] = true
-					r.insertRoute(
-						method,
-						path,
-						r.tsrHandler(),
-						staticRouteNode,
-						nil,
-					)
-				}
-			}
+// Route is a registered route.
+type Route struct {
+	// Host is the host of the route in lower case. It is empty if the route
+	// is registered without a host.
+	Host string
 
-			pathParamNames = append(pathParamNames, "*")
+	// Method is the method of the route. It is empty if the route is a
+	// catch-all one.
+	Method string
 
-			r.insertRoute(
-				method,
-				path[:i+1],
-				h,
-				wildcardParamRouteNode,
-				pathParamNames,
-			)
-		}
-	}
+	// Pattern is the path of the route as it is registered, with the
+	// [Router.PathPrefix] (and the ones of all the [Router.Parent]s)
+	// prepended.
+	Pattern string
+
+	// Name is the name of the route. See the [RouteName].
+	Name string
+
+	// PathParamNames is the path parameter names of the route. The name of
+	// a wildcard path parameter always starts with '*'.
+	PathParamNames []string
+
+	// Middlewares is the number of the [Middleware] chain of the route,
+	// excluding the [Matcher]s, the results of the [RouteName] and the nil
+	// ones.
+	Middlewares int
+
+	// TSR indicates whether the route is a catch-all one automatically
+	// registered to write TSR (Trailing Slash Redirect) responses. See the
+	// [Router.Handle] for more details.
+	TSR bool
 
-	r.insertRoute(method, path, h, staticRouteNode, pathParamNames)
+	// handler and middlewares are the ones the route is registered with,
+	// which are kept for the [Router.Attach].
+	handler     http.Handler
+	middlewares []Middleware
 }
 
-// insertRoute inserts a new route into the r.routeTree.
-func (r *Router) insertRoute(
-	method string,
-	path string,
-	h http.Handler,
-	nt routeNodeType,
-	pathParamNames []string,
-) {
-	if l := len(pathParamNames); r.maxPathParams < l {
-		r.maxPathParams = l
-		r.pathParamValuesPool = sync.Pool{
-			New: func() interface{} {
-				return make([]string, l)
-			},
+// Remove removes the routes registered for the method and path (see the
+// [Router.Handle]), along with the r.Host, regardless of their [Matcher]s. It
+// reports whether any route is removed.
+//
+// The path must be written as it is registered. For a route with optional path
+// segments, all the paths it represents are removed. Nodes of the route radix
+// tree that become useless are pruned or merged, and the TSR (Trailing Slash
+// Redirect) routes are removed or restored as needed.
+//
+// A route can be replaced by removing it and then registering a new one.
+func (r *Router) Remove(method, path string) bool {
+	return r.removeHost(r.Host, method, path)
+}
+
+// removeHost is like the [Router.Remove], but removes the routes for the host
+// instead of the r.Host.
+func (r *Router) removeHost(host, method, path string) bool {
+	if r.Parent != nil {
+		if host == "" {
+			host = r.Parent.Host
 		}
+
+		return r.Parent.removeHost(host, method, r.PathPrefix+path)
 	}
 
-	var (
-		s  = path        // Search
-		sl int           // Search length
-		pl int           // Prefix length
-		ll int           // LCP length
-		ml int           // Minimum length of the sl and pl
-		cn = r.routeTree // Current node
-		nn *routeNode    // Next node
-	)
+	path = r.PathPrefix + path
+	if !isRouteMethod(method) || path == "" || path[0] != '/' {
+		return false
+	}
 
-	for {
-		sl, pl, ll = len(s), len(cn.prefix), 0
-		if sl < pl {
-			ml = sl
-		} else {
-			ml = pl
+	rps, err := parseRoutePaths(path)
+	if err != nil {
+		return false
+	}
+
+	r.tableMutex.Lock()
+	defer r.tableMutex.Unlock()
+
+	tb := r.loadTable()
+	host = strings.ToLower(host)
+	if tb.routeTree == nil ||
+		host != "" && tb.hostRouteTree(host) == nil {
+		return false
+	}
+
+	tb = r.writableTable()
+
+	rt := tb.routeTree
+	if host != "" {
+		rt = tb.hostRouteTree(host).routeTree
+	}
+
+	var removedKeys []string
+	for _, rp := range rps {
+		n := rt.node(rp.path, rp.pathParamConstraints)
+		if n == nil || !n.removeHandler(method) {
+			continue
 		}
 
-		for ; ll < ml && s[ll] == cn.prefix[ll]; ll++ {
+		delete(tb.registeredRoutes, host+" "+method+rp.key)
+		removedKeys = append(removedKeys, rp.key)
+		n.prune()
+
+		if len(rp.pathParamNames) == 0 {
+			r.syncTSRRoute(tb, rt, host, rp.path)
+		} else if l := len(rp.path); len(rp.pathParamNames) == 1 &&
+			l > 2 &&
+			rp.path[l-2:] == "/*" {
+			r.syncTSRRoute(tb, rt, host, rp.path[:l-2])
 		}
+	}
 
-		if ll == 0 { // At root node
-			cn.prefix = s
-			cn.label = s[0]
-			if h != nil {
-				cn.typ = nt
-				cn.pathParamNames = pathParamNames
-				cn.setHandler(method, h)
-			}
-		} else if ll < pl { // Split node
-			nn = &routeNode{
-				prefix:               cn.prefix[ll:],
-				label:                cn.prefix[ll],
-				typ:                  cn.typ,
-				parent:               cn,
-				staticChildren:       cn.staticChildren,
-				paramChild:           cn.paramChild,
-				wildcardParamChild:   cn.wildcardParamChild,
-				hasAtLeastOneChild:   cn.hasAtLeastOneChild,
-				pathParamNames:       cn.pathParamNames,
-				methodHandlers:       cn.methodHandlers,
-				otherMethodHandlers:  cn.otherMethodHandlers,
-				catchAllHandler:      cn.catchAllHandler,
-				hasAtLeastOneHandler: cn.hasAtLeastOneHandler,
-			}
+	if len(removedKeys) == 0 {
+		return false
+	}
 
-			for _, n := range nn.staticChildren {
-				if n != nil {
-					n.parent = nn
-				}
+	isRemoved := func(key string) bool {
+		for _, rk := range removedKeys {
+			if rk == key {
+				return true
 			}
+		}
 
-			if nn.paramChild != nil {
-				nn.paramChild.parent = nn
-			}
+		return false
+	}
 
-			if nn.wildcardParamChild != nil {
-				nn.wildcardParamChild.parent = nn
+	routes := make([]Route, 0, len(tb.routes))
+	for _, route := range tb.routes {
+		if route.TSR {
+			tsrRouteName := route.Host + " _tsr" + route.Pattern
+			if !tb.registeredRoutes[tsrRouteName] {
+				continue
+			}
+		} else if route.Host == host && route.Method == method {
+			rps, _ := parseRoutePaths(route.Pattern)
+			remaining := 0
+			for _, rp := range rps {
+				n := rt.node(rp.path, rp.pathParamConstraints)
+				if n != nil && n.hasHandler(method) {
+					remaining++
+				}
 			}
 
-			// Reset current node.
-			cn.prefix = cn.prefix[:ll]
-			cn.label = cn.prefix[0]
-			cn.typ = staticRouteNode
-			cn.staticChildren = make([]*routeNode, 255)
-			cn.paramChild = nil
-			cn.wildcardParamChild = nil
-			cn.hasAtLeastOneChild = false
-			cn.pathParamNames = nil
-			cn.methodHandlers = &methodHandlers{}
-			cn.otherMethodHandlers = nil
-			cn.catchAllHandler = nil
-			cn.hasAtLeastOneHandler = false
-			cn.addChild(nn)
+			if route.Name != "" {
+				var ups []*urlPattern
+				for _, up := range tb.namedRoutes[route.Name] {
+					if !isRemoved(up.key) {
+						ups = append(ups, up)
+					}
+				}
 
-			if ll == sl { // At current node
-				cn.typ = nt
-				cn.pathParamNames = pathParamNames
-				cn.setHandler(method, h)
-			} else { // Create child node
-				nn = &routeNode{
-					prefix:         s[ll:],
-					label:          s[ll],
-					typ:            nt,
-					parent:         cn,
-					staticChildren: make([]*routeNode, 255),
-					pathParamNames: pathParamNames,
-					methodHandlers: &methodHandlers{},
+				if len(ups) > 0 {
+					tb.namedRoutes[route.Name] = ups
+				} else {
+					delete(tb.namedRoutes, route.Name)
 				}
+			}
 
-				nn.setHandler(method, h)
+			if remaining == 0 {
+				continue
+			}
+		}
 
-				cn.addChild(nn)
+		routes = append(routes, route)
+	}
+
+	tb.routes = routes
+	r.table.Store(tb)
+
+	return true
+}
+
+// syncTSRRoute registers or removes the TSR route for the path in the rt of
+// the tb for the host, so that it exists if and only if a route whose path is
+// the path followed by "/*" exists. The path must not have any path
+// parameters.
+func (r *Router) syncTSRRoute(
+	tb *routeTable,
+	rt *routeNode,
+	host string,
+	path string,
+) {
+	routeName := host + " _tsr" + path
+	wn := rt.node(path+"/*", []*pathParamConstraint{nil})
+	if wn != nil && wn.hasAtLeastOneHandler {
+		tb.insertRoute(
+			rt,
+			"_tsr",
+			path,
+			r.tsrHandler(),
+			staticRouteNode,
+			nil,
+			nil,
+		)
+		return
+	}
+
+	if n := rt.node(path, nil); n != nil && n.removeHandler("_tsr") {
+		n.prune()
+	}
+
+	delete(tb.registeredRoutes, routeName)
+}
+
+// isRouteMethod reports whether the method can be used to register routes.
+func isRouteMethod(method string) bool {
+	for _, c := range method {
+		if (c < '0' || c > '9') &&
+			(c < 'A' || c > 'Z') &&
+			(c < 'a' || c > 'z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// routePath is a parsed route path.
+type routePath struct {
+	// path is the route path with the names and the constraints of its
+	// path parameters cut out, such as "/users/:/posts/*".
+	path string
+
+	pathParamNames       []string
+	pathParamConstraints []*pathParamConstraint
+
+	// key identifies all the route paths that are matched by the same
+	// requests.
+	key string
+}
+
+// parseRoutePaths parses the path into all the [routePath]s it represents.
+func parseRoutePaths(path string) ([]*routePath, *RouteError) {
+	path, pathParamConstraintPatterns, err := cutPathParamConstraints(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if path, err = markOptionalPathParams(path); err != nil {
+		return nil, err
+	}
+
+	paths, err := expandOptionalPathSegments(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rps := make([]*routePath, 0, len(paths))
+	for _, path := range paths {
+		rp, err := parseRoutePath(path, pathParamConstraintPatterns)
+		if err != nil {
+			return nil, err
+		}
+
+		rps = append(rps, rp)
+	}
+
+	return rps, nil
+}
+
+// parseRoutePath parses the path, which must be a result of the
+// [expandOptionalPathSegments] with its path parameter constraints cut into the
+// pathParamConstraintPatterns.
+func parseRoutePath(
+	path string,
+	pathParamConstraintPatterns []string,
+) (*routePath, *RouteError) {
+	hasTrailingSlash := path[len(path)-1] == '/'
+	path = stdpath.Clean(path)
+	if hasTrailingSlash && path != "/" {
+		path += "/"
+	}
+
+	if strings.Contains(path, "*") {
+		if strings.Count(path, "*") > 1 {
+			return nil, &RouteError{
+				Err: ErrConflictingWildcardParam,
+				Detail: "only one '*' is allowed in a route " +
+					"path",
 			}
-		} else if ll < sl {
-			s = s[ll:]
+		}
 
-			nn = nil
-			switch s[0] {
-			case ':':
-				nn = cn.paramChild
-			case '*':
-				nn = cn.wildcardParamChild
-			default:
-				nn = cn.staticChildren[s[0]]
+		i := strings.IndexByte(path, '*')
+		k := i + 1
+		for k < len(path) && isPathParamNameByte(path[k]) {
+			k++
+		}
+
+		if k < len(path) && path[k] != '/' {
+			return nil, &RouteError{
+				Err: ErrConflictingWildcardParam,
+				Detail: "'*' can only appear at the end of a " +
+					"route path or before a '/'",
 			}
+		}
 
-			if nn != nil {
-				// Go deeper.
-				cn = nn
-				continue
+		j := strings.LastIndexByte(path[:i], '/')
+		if strings.Contains(path[j:i], ":") {
+			return nil, &RouteError{
+				Err: ErrConflictingWildcardParam,
+				Detail: "':' and '*' cannot appear in the " +
+					"same route path element",
 			}
+		}
+	}
 
-			// Create child node.
-			nn = &routeNode{
-				prefix:         s,
-				label:          s[0],
-				typ:            nt,
-				parent:         cn,
-				staticChildren: make([]*routeNode, 255),
-				pathParamNames: pathParamNames,
-				methodHandlers: &methodHandlers{},
+	var (
+		pathParamNames       []string
+		pathParamConstraints []*pathParamConstraint
+	)
+	for i, l := 0, len(path); i < l; i++ {
+		switch path[i] {
+		case ':':
+			j := i + 1
+			for i = j; i < l && isPathParamNameByte(path[i]); i++ {
+			}
+
+			pathParamName := path[j:i]
+			if pathParamName == "" {
+				return nil, &RouteError{
+					Err: ErrInvalidRoutePath,
+					Detail: "route path parameter name " +
+						"cannot be empty",
+				}
+			}
+
+			for _, pn := range pathParamNames {
+				pn = strings.TrimPrefix(pn, "*")
+				if pn == pathParamName {
+					return nil, &RouteError{
+						Err: ErrInvalidRoutePath,
+						Detail: "route path cannot " +
+							"have duplicate " +
+							"parameter names",
+					}
+				}
+			}
+
+			var (
+				ppc *pathParamConstraint
+				err *RouteError
+			)
+			if i < l && path[i] == ':' {
+				k := i + 1
+				for i = k; i < l; i++ {
+					if !isPathParamNameByte(path[i]) {
+						break
+					}
+				}
+
+				typ := path[k:i]
+				ppc, err = newTypedPathParamConstraint(typ)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if i < l && path[i] == '<' {
+				if ppc != nil {
+					return nil, &RouteError{
+						Err: ErrInvalidRoutePath,
+						Detail: "route path " +
+							"parameter cannot " +
+							"have both a type " +
+							"and a constraint",
+					}
+				}
+
+				k := i + strings.IndexByte(path[i:], '>')
+				n, _ := strconv.Atoi(path[i+1 : k])
+				ppc, err = newPathParamConstraint(
+					pathParamConstraintPatterns[n],
+				)
+				if err != nil {
+					return nil, err
+				}
+
+				i = k + 1
+			}
+
+			// Path parameter names used to run to the end of the
+			// path element, so a name followed by static characters
+			// (such as ":user-id") is rejected instead of silently
+			// changing its meaning when nothing else ends it.
+			if ppc == nil && i < l && path[i] != '/' {
+				es := strings.LastIndexByte(path[:j], '/')
+				ee := strings.IndexByte(path[i:], '/')
+				if ee < 0 {
+					ee = l - i
+				}
+
+				if strings.Count(path[es:i+ee], ":") == 1 {
+					return nil, &RouteError{
+						Err: ErrInvalidRoutePath,
+						Detail: "route path " +
+							"parameter followed " +
+							"by static " +
+							"characters must " +
+							"have a type or a " +
+							"constraint",
+					}
+				}
+			}
+
+			pathParamNames = append(pathParamNames, pathParamName)
+			pathParamConstraints = append(pathParamConstraints, ppc)
+			path = path[:j] + path[i:]
+
+			i, l = j-1, len(path)
+			if j < l && path[j] == ':' {
+				return nil, &RouteError{
+					Err: ErrInvalidRoutePath,
+					Detail: "route path parameters must " +
+						"be separated by static " +
+						"characters",
+				}
+			}
+		case '*':
+			j := i + 1
+			for i = j; i < l && isPathParamNameByte(path[i]); i++ {
+			}
+
+			pathParamName := path[j:i]
+			for _, pn := range pathParamNames {
+				if pn == pathParamName {
+					return nil, &RouteError{
+						Err: ErrInvalidRoutePath,
+						Detail: "route path cannot " +
+							"have duplicate " +
+							"parameter names",
+					}
+				}
+			}
+
+			pathParamName = "*" + pathParamName
+			pathParamNames = append(pathParamNames, pathParamName)
+			pathParamConstraints = append(pathParamConstraints, nil)
+			path = path[:j] + path[i:]
+			i, l = j-1, len(path)
+		}
+	}
+
+	var key string
+	for i, j, ppi := 0, 0, 0; i <= len(path); i++ {
+		if i == len(path) {
+			key += path[j:]
+			break
+		}
+
+		switch path[i] {
+		case ':', '*':
+			if ppc := pathParamConstraints[ppi]; ppc != nil {
+				key += path[j:i+1] + ppc.key
+				j = i + 1
+			}
+
+			ppi++
+		}
+	}
+
+	return &routePath{
+		path:                 path,
+		pathParamNames:       pathParamNames,
+		pathParamConstraints: pathParamConstraints,
+		key:                  key,
+	}, nil
+}
+
+// handle registers a new route named the name (empty string means unnamed) into
+// the rt for the host, method and rp with the matching h and ms. The route must
+// have been validated by the [Router.register].
+//
+// It returns the path of the TSR route automatically registered for the route
+// (empty string means none).
+func (r *Router) handle(
+	tb *routeTable,
+	rt *routeNode,
+	host string,
+	name string,
+	method string,
+	rp *routePath,
+	h http.Handler,
+	ms []Middleware,
+) string {
+	var (
+		path                 = rp.path
+		pathParamNames       = rp.pathParamNames
+		pathParamConstraints = rp.pathParamConstraints
+	)
+
+	ms = append(r.Middlewares, ms...)
+
+	var matchers []Matcher
+	for _, m := range ms {
+		if m, ok := m.(Matcher); ok {
+			matchers = append(matchers, m)
+		}
+	}
+
+	if len(matchers) == 0 {
+		tb.registeredRoutes[host+" "+method+rp.key] = true
+	}
+
+	for i := len(ms) - 1; i >= 0; i-- {
+		if ms[i] != nil {
+			h = ms[i].ChainHTTPHandler(h)
+		}
+	}
+
+	if len(pathParamNames) > 0 {
+		ph := h
+		h = http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			// The data may be reset by another lookup with the
+			// same req while serving, such as one performed by a
+			// mounted Router.
+			d, ok := req.Context().Value(dataContextKey).(*data)
+			if !ok || d.pathParamValues == nil {
+				ph.ServeHTTP(rw, req)
+				return
+			}
+
+			ppvs, ppvsp := d.pathParamValues, d.pathParamValuesPool
+			ph.ServeHTTP(rw, req)
+
+			//lint:ignore SA6002 this is harmless
+			ppvsp.Put(ppvs)
+		})
+	}
+
+	if len(matchers) > 0 {
+		h = &matchingHandler{
+			routes: []*matchingRoute{{
+				matchers: matchers,
+				handler:  h,
+			}},
+		}
+	}
+
+	var tsrPath string
+
+	ppi := 0
+	for i, l := 0, len(path); i < l; i++ {
+		switch path[i] {
+		case ':':
+			tb.insertRoute(
+				rt,
+				method,
+				path[:i],
+				nil,
+				staticRouteNode,
+				nil,
+				pathParamConstraints,
+			)
+
+			ppi++
+			if i+1 < l {
+				tb.insertRoute(
+					rt,
+					method,
+					path[:i+1],
+					nil,
+					paramRouteNode,
+					pathParamNames[:ppi],
+					pathParamConstraints,
+				)
+			} else {
+				tb.insertRoute(
+					rt,
+					method,
+					path[:i+1],
+					h,
+					paramRouteNode,
+					pathParamNames[:ppi],
+					pathParamConstraints,
+				)
+			}
+		case '*':
+			tb.insertRoute(
+				rt,
+				method,
+				path[:i],
+				nil,
+				staticRouteNode,
+				nil,
+				pathParamConstraints,
+			)
+
+			offset := i - strings.LastIndexByte(path[:i], '/') - 1
+			if offset == 0 && i > 1 && i == l-1 && ppi == 0 {
+				method, path := "_tsr", path[:i-1]
+				routeName := host + " " + method + path
+				if !tb.registeredRoutes[routeName] {
+					tb.registeredRoutes[routeName] = true
+					tsrPath = path
+					tb.insertRoute(
+						rt,
+						method,
+						path,
+						r.tsrHandler(),
+						staticRouteNode,
+						nil,
+						nil,
+					)
+				}
 			}
 
-			nn.setHandler(method, h)
+			ppi++
+			if i+1 < l {
+				tb.insertRoute(
+					rt,
+					method,
+					path[:i+1],
+					nil,
+					wildcardParamRouteNode,
+					pathParamNames[:ppi],
+					pathParamConstraints,
+				)
+			} else {
+				tb.insertRoute(
+					rt,
+					method,
+					path[:i+1],
+					h,
+					wildcardParamRouteNode,
+					pathParamNames[:ppi],
+					pathParamConstraints,
+				)
+			}
+		}
+	}
+
+	tb.insertRoute(
+		rt,
+		method,
+		path,
+		h,
+		staticRouteNode,
+		pathParamNames,
+		pathParamConstraints,
+	)
+
+	if name != "" {
+		tb.namedRoutes[name] = append(tb.namedRoutes[name], &urlPattern{
+			path:                 path,
+			pathParamNames:       pathParamNames,
+			pathParamConstraints: pathParamConstraints,
+			key:                  rp.key,
+		})
+	}
+
+	return tsrPath
+}
+
+// URL returns the path of the route named the name (see the [RouteName]) built
+// with the params, which are pairs of path parameter names and values, such as
+// "id", "1". The values are escaped properly.
+//
+// For a route with optional path segments, the path is built from the longest
+// one of all the paths the route represents that uses all the params.
+//
+// A [URLError] is returned when the route is not found, a path parameter is
+// missing or unknown, or a value does not match its path parameter.
+func (r *Router) URL(name string, params ...string) (string, error) {
+	return r.URLWithQuery(name, nil, params...)
+}
+
+// URLWithQuery is like the [Router.URL], but also appends the encoded query to
+// the built path when it is not empty.
+func (r *Router) URLWithQuery(
+	name string,
+	query url.Values,
+	params ...string,
+) (string, error) {
+	if r.Parent != nil {
+		return r.Parent.URLWithQuery(name, query, params...)
+	}
+
+	ups := r.loadTable().namedRoutes[name]
+	if len(ups) == 0 {
+		return "", &URLError{Route: name, Err: ErrRouteNotFound}
+	}
+
+	if len(params)%2 != 0 {
+		return "", &URLError{
+			Route:     name,
+			PathParam: params[len(params)-1],
+			Err:       ErrInvalidPathParam,
+		}
+	}
+
+	for i := 0; i < len(params); i += 2 {
+		var known bool
+		for _, up := range ups {
+			if up.pathParamIndex(params[i]) >= 0 {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return "", &URLError{
+				Route:     name,
+				PathParam: params[i],
+				Err:       ErrUnknownPathParam,
+			}
+		}
+	}
+
+	var up *urlPattern
+	for _, p := range ups {
+		if p.missingPathParam(params) != "" {
+			continue
+		}
+
+		if up == nil || len(p.pathParamNames) > len(up.pathParamNames) {
+			up = p
+		}
+	}
+
+	if up == nil {
+		return "", &URLError{
+			Route:     name,
+			PathParam: ups[0].missingPathParam(params),
+			Err:       ErrMissingPathParam,
+		}
+	}
+
+	for i := 0; i < len(params); i += 2 {
+		if up.pathParamIndex(params[i]) >= 0 {
+			continue
+		}
+
+		var mpn string
+		for _, p := range ups {
+			if p.pathParamIndex(params[i]) >= 0 {
+				mpn = p.missingPathParam(params)
+				break
+			}
+		}
+
+		return "", &URLError{
+			Route:     name,
+			PathParam: mpn,
+			Err:       ErrMissingPathParam,
+		}
+	}
+
+	path, invalidPathParam := up.build(params)
+	if invalidPathParam != "" {
+		return "", &URLError{
+			Route:     name,
+			PathParam: invalidPathParam,
+			Err:       ErrInvalidPathParam,
+		}
+	}
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	return path, nil
+}
+
+// hostRouteTree returns the [hostRouteTree] of the tb for the host. It returns
+// nil if not found.
+func (tb *routeTable) hostRouteTree(host string) *hostRouteTree {
+	for _, hrt := range tb.hostRouteTrees {
+		if hrt.host == host {
+			return hrt
+		}
+	}
+
+	return nil
+}
+
+// addHostRouteTree adds the hrt to the tb. Host route trees without host
+// parameters are kept before the others.
+func (tb *routeTable) addHostRouteTree(hrt *hostRouteTree) {
+	i := len(tb.hostRouteTrees)
+	if len(hrt.hostParamNames) == 0 {
+		for i = 0; i < len(tb.hostRouteTrees); i++ {
+			if len(tb.hostRouteTrees[i].hostParamNames) > 0 {
+				break
+			}
+		}
+	}
+
+	tb.hostRouteTrees = append(tb.hostRouteTrees, nil)
+	copy(tb.hostRouteTrees[i+1:], tb.hostRouteTrees[i:])
+	tb.hostRouteTrees[i] = hrt
+}
+
+// RouteName returns a [Middleware] that names the route registered with it,
+// which must be unique within a [Router]. It does nothing to the [http.Handler]
+// it chains. See the [Router.URL] for its usage.
+func RouteName(name string) Middleware {
+	return routeNameMiddleware(name)
+}
+
+// routeNameMiddleware is the [Middleware] returned by the [RouteName].
+type routeNameMiddleware string
+
+// ChainHTTPHandler implements the [Middleware]. It returns the next as is.
+func (rnm routeNameMiddleware) ChainHTTPHandler(
+	next http.Handler,
+) http.Handler {
+	return next
+}
+
+var (
+	// ErrInvalidRouteMethod is the error wrapped in the [RouteError] when
+	// the method of a route is not alphanumeric.
+	ErrInvalidRouteMethod = errors.New("invalid route method")
+
+	// ErrInvalidRoutePath is the error wrapped in the [RouteError] when the
+	// path of a route is malformed, such as having a bad path parameter
+	// syntax.
+	ErrInvalidRoutePath = errors.New("invalid route path")
+
+	// ErrConflictingWildcardParam is the error wrapped in the [RouteError]
+	// when a wildcard path parameter conflicts with the rest of the path of
+	// a route, such as another '*' or a ':' in the same path element.
+	ErrConflictingWildcardParam = errors.New(
+		"conflicting wildcard path parameter",
+	)
+
+	// ErrInvalidRouteHost is the error wrapped in the [RouteError] when the
+	// host of a route is malformed.
+	ErrInvalidRouteHost = errors.New("invalid route host")
+
+	// ErrRouteAlreadyExists is the error wrapped in the [RouteError] when a
+	// route with the same host, method and path (without any [Matcher]s)
+	// already exists.
+	ErrRouteAlreadyExists = errors.New("route already exists")
+
+	// ErrConflictingPathParamNames is the error wrapped in the [RouteError]
+	// when a route shares the same host, method and path with an existing
+	// one (with [Matcher]s) but declares different path parameter names.
+	ErrConflictingPathParamNames = errors.New(
+		"conflicting path parameter names",
+	)
+
+	// ErrRouteNameAlreadyExists is the error wrapped in the [RouteError]
+	// when a route with the same name already exists.
+	ErrRouteNameAlreadyExists = errors.New("route name already exists")
+
+	// ErrNilRouteHandler is the error wrapped in the [RouteError] when the
+	// handler of a route is nil.
+	ErrNilRouteHandler = errors.New("nil route handler")
+
+	// ErrInvalidAttachedRouter is the error wrapped in the [RouteError]
+	// when a [Router] cannot be attached by the [Router.TryAttach], such as
+	// having a [Router.Parent].
+	ErrInvalidAttachedRouter = errors.New("invalid attached router")
+)
+
+// RouteError is the error returned by the [Router.TryHandle] when it fails to
+// register a route.
+type RouteError struct {
+	// Method is the method of the route.
+	Method string
+
+	// Path is the path of the route, with the [Router.PathPrefix] (and the
+	// ones of all the [Router.Parent]s) prepended.
+	Path string
+
+	// Err is the underlying error.
+	Err error
+
+	// Detail describes the reason of the Err.
+	Detail string
+}
+
+// Error implements the [error].
+func (e *RouteError) Error() string {
+	s := "r2: cannot register route " + strconv.Quote(e.Path)
+	if e.Method != "" {
+		s += " for method " + strconv.Quote(e.Method)
+	}
+
+	return s + ": " + e.Detail
+}
+
+// Unwrap returns the underlying error of the e.
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// ErrRouteNotFound is the error wrapped in the [URLError] when the
+	// route is not found.
+	ErrRouteNotFound = errors.New("route not found")
+
+	// ErrMissingPathParam is the error wrapped in the [URLError] when a
+	// path parameter is missing.
+	ErrMissingPathParam = errors.New("missing path parameter")
+
+	// ErrUnknownPathParam is the error wrapped in the [URLError] when a
+	// path parameter is unknown.
+	ErrUnknownPathParam = errors.New("unknown path parameter")
+
+	// ErrInvalidPathParam is the error wrapped in the [URLError] when a
+	// value does not match its path parameter, or when a path parameter
+	// name is not followed by a value.
+	ErrInvalidPathParam = errors.New("invalid path parameter")
+)
+
+// URLError is the error returned by the [Router.URL] and the
+// [Router.URLWithQuery] when they fail to build a URL.
+type URLError struct {
+	// Route is the name of the route.
+	Route string
+
+	// PathParam is the name of the path parameter that causes the Err. It
+	// is empty if the Err is not caused by a path parameter.
+	PathParam string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the [error].
+func (e *URLError) Error() string {
+	s := "r2: cannot build url for route " + strconv.Quote(e.Route)
+	if e.PathParam != "" {
+		s += " with path parameter " + strconv.Quote(e.PathParam)
+	}
+
+	return s + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error of the e.
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+// urlPattern is a pattern of a named route for building URLs.
+type urlPattern struct {
+	path                 string
+	pathParamNames       []string
+	pathParamConstraints []*pathParamConstraint
+	key                  string
+}
+
+// pathParamIndex returns the index of the path parameter of the up for the
+// name. It returns -1 if not found.
+func (up *urlPattern) pathParamIndex(name string) int {
+	for i, ppn := range up.pathParamNames {
+		if pathParamNameMatches(ppn, name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// missingPathParam returns the name of the first path parameter of the up that
+// is missing from the params. It returns empty string if not found.
+func (up *urlPattern) missingPathParam(params []string) string {
+	for _, ppn := range up.pathParamNames {
+		var found bool
+		for i := 0; i < len(params); i += 2 {
+			if pathParamNameMatches(ppn, params[i]) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return ppn
+		}
+	}
+
+	return ""
+}
+
+// build builds a path from the up with the params. It returns the name of the
+// first path parameter whose value is invalid along with an empty path if
+// found.
+func (up *urlPattern) build(params []string) (string, string) {
+	var (
+		b   strings.Builder
+		ppi int
+	)
+
+	for i, j := 0, 0; i <= len(up.path); i++ {
+		if i < len(up.path) && up.path[i] != ':' && up.path[i] != '*' {
+			continue
+		}
+
+		b.WriteString(escapePath(up.path[j:i]))
+		j = i + 1
+		if i == len(up.path) {
+			break
+		}
+
+		ppn := up.pathParamNames[ppi]
+		ppc := up.pathParamConstraints[ppi]
+		ppi++
+
+		var ppv string
+		for k := 0; k < len(params); k += 2 {
+			if pathParamNameMatches(ppn, params[k]) {
+				ppv = params[k+1]
+			}
+		}
+
+		if up.path[i] == ':' {
+			if ppv == "" || ppc != nil && !ppc.match(ppv) {
+				return "", ppn
+			}
+
+			b.WriteString(url.PathEscape(ppv))
+		} else {
+			if ppv == "" && i < len(up.path)-1 {
+				return "", ppn
+			}
+
+			b.WriteString(escapePath(ppv))
+		}
+	}
+
+	return b.String(), ""
+}
+
+// escapePath escapes the path so that it can be safely placed inside a URL
+// path, keeping its '/'s as they are.
+func escapePath(path string) string {
+	elements := strings.Split(path, "/")
+	for i, e := range elements {
+		elements[i] = url.PathEscape(e)
+	}
+
+	return strings.Join(elements, "/")
+}
+
+// cutPathParamConstraints cuts all path parameter constraints out of the path
+// and returns the remaining path along with the patterns of the cut
+// constraints. Each cut constraint leaves its index in the returned patterns
+// wrapped in "<>" in the remaining path.
+func cutPathParamConstraints(
+	path string,
+) (string, []string, *RouteError) {
+	var patterns []string
+	for i, l := 0, len(path); i < l; i++ {
+		if path[i] != ':' {
+			continue
+		}
+
+		for i++; i < l && isPathParamNameByte(path[i]); i++ {
+		}
+
+		if i < l && path[i] == ':' {
+			for i++; i < l && isPathParamNameByte(path[i]); i++ {
+			}
+		}
+
+		if i == l || path[i] != '<' {
+			i--
+			continue
+		}
+
+		j, depth := i+1, 1
+		for ; j < l && depth > 0; j++ {
+			switch path[j] {
+			case '\\':
+				j++
+			case '<':
+				depth++
+			case '>':
+				depth--
+			}
+		}
+
+		if depth > 0 {
+			return "", nil, &RouteError{
+				Err: ErrInvalidRoutePath,
+				Detail: "route path parameter constraint " +
+					"must end with '>'",
+			}
+		}
+
+		n := strconv.Itoa(len(patterns))
+		patterns = append(patterns, path[i+1:j-1])
+		path = path[:i+1] + n + path[j-1:]
+
+		i, l = i+len(n)+1, len(path)
+	}
+
+	return path, patterns, nil
+}
+
+// markOptionalPathParams rewrites every path parameter followed by a '?' in the
+// path, along with the '/' before it, into an optional path segment wrapped in
+// "()". The path parameter constraints must have been cut out of the path.
+func markOptionalPathParams(path string) (string, *RouteError) {
+	for i := strings.IndexByte(path, '?'); i >= 0; {
+		j := i
+		if path[j-1] == '>' {
+			if k := strings.LastIndexByte(path[:j], '<'); k > 0 {
+				j = k
+			}
+		}
+
+		for j > 0 && isPathParamNameByte(path[j-1]) {
+			j--
+		}
+
+		if j > 1 && path[j-1] == ':' && isPathParamNameByte(path[j-2]) {
+			for j--; j > 0 && isPathParamNameByte(path[j-1]); j-- {
+			}
+		}
+
+		if j < 2 ||
+			(path[j-1] != ':' && path[j-1] != '*') ||
+			path[j-2] != '/' {
+			return "", &RouteError{
+				Err: ErrInvalidRoutePath,
+				Detail: "'?' can only follow a route path " +
+					"parameter that starts a path element",
+			}
+		}
+
+		path = path[:j-2] + "(" + path[j-2:i] + ")" + path[i+1:]
+		i = strings.IndexByte(path, '?')
+	}
+
+	return path, nil
+}
+
+// expandOptionalPathSegments expands the path into all the paths it represents
+// by including or omitting each of its optional path segments wrapped in "()".
+// The path parameter constraints must have been cut out of the path.
+func expandOptionalPathSegments(path string) ([]string, *RouteError) {
+	i := strings.IndexAny(path, "()")
+	if i < 0 {
+		return []string{path}, nil
+	} else if path[i] == ')' {
+		return nil, &RouteError{
+			Err: ErrInvalidRoutePath,
+			Detail: "route path optional segment must start " +
+				"with '('",
+		}
+	}
+
+	j, depth := i+1, 1
+	for ; j < len(path) && depth > 0; j++ {
+		switch path[j] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+
+	if depth > 0 {
+		return nil, &RouteError{
+			Err: ErrInvalidRoutePath,
+			Detail: "route path optional segment must end " +
+				"with ')'",
+		}
+	} else if j == i+2 {
+		return nil, &RouteError{
+			Err:    ErrInvalidRoutePath,
+			Detail: "route path optional segment cannot be empty",
+		}
+	}
+
+	segments, err := expandOptionalPathSegments(path[i+1 : j-1])
+	if err != nil {
+		return nil, err
+	}
+
+	rests, err := expandOptionalPathSegments(path[j:])
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, rest := range rests {
+		paths = append(paths, path[:i]+rest)
+		for _, segment := range segments {
+			paths = append(paths, path[:i]+segment+rest)
+		}
+	}
+
+	return paths, nil
+}
+
+// insertRoute inserts a new route into the rt.
+func (tb *routeTable) insertRoute(
+	rt *routeNode,
+	method string,
+	path string,
+	h http.Handler,
+	nt routeNodeType,
+	pathParamNames []string,
+	pathParamConstraints []*pathParamConstraint,
+) {
+	if l := len(pathParamNames); tb.maxPathParams < l {
+		tb.maxPathParams = l
+		tb.pathParamValuesPool = &sync.Pool{
+			New: func() interface{} {
+				return make([]string, l)
+			},
+		}
+	}
+
+	var (
+		s   = path               // Search
+		sl  int                  // Search length
+		pl  int                  // Prefix length
+		ll  int                  // LCP length
+		ml  int                  // Minimum length of the sl and pl
+		cn  = rt                 // Current node
+		nn  *routeNode           // Next node
+		ppi int                  // Path parameter index
+		ppc *pathParamConstraint // Path parameter constraint
+	)
+
+	for {
+		sl, pl, ll = len(s), len(cn.prefix), 0
+		if sl < pl {
+			ml = sl
+		} else {
+			ml = pl
+		}
+
+		for ; ll < ml && s[ll] == cn.prefix[ll]; ll++ {
+		}
+
+		if ll == 0 { // At root node
+			cn.prefix = s
+			cn.label = s[0]
+			if h != nil {
+				cn.typ = nt
+				cn.setPathParamNames(method, pathParamNames)
+				cn.setHandler(method, h)
+			}
+		} else if ll < pl { // Split node
+			nn = &routeNode{
+				prefix:               cn.prefix[ll:],
+				label:                cn.prefix[ll],
+				typ:                  cn.typ,
+				parent:               cn,
+				staticChildren:       cn.staticChildren,
+				paramChildren:        cn.paramChildren,
+				wildcardParamChild:   cn.wildcardParamChild,
+				hasAtLeastOneChild:   cn.hasAtLeastOneChild,
+				pathParamNames:       cn.pathParamNames,
+				methodPathParamNames: cn.methodPathParamNames,
+				pathParamConstraint:  cn.pathParamConstraint,
+				methodHandlers:       cn.methodHandlers,
+				otherMethodHandlers:  cn.otherMethodHandlers,
+				catchAllHandler:      cn.catchAllHandler,
+				hasAtLeastOneHandler: cn.hasAtLeastOneHandler,
+			}
+
+			for _, n := range nn.staticChildren {
+				if n != nil {
+					n.parent = nn
+				}
+			}
+
+			for _, n := range nn.paramChildren {
+				n.parent = nn
+			}
+
+			if nn.wildcardParamChild != nil {
+				nn.wildcardParamChild.parent = nn
+			}
+
+			// Reset current node.
+			cn.prefix = cn.prefix[:ll]
+			cn.label = cn.prefix[0]
+			cn.typ = staticRouteNode
+			cn.staticChildren = make([]*routeNode, 255)
+			cn.paramChildren = nil
+			cn.wildcardParamChild = nil
+			cn.hasAtLeastOneChild = false
+			cn.pathParamNames = nil
+			cn.methodPathParamNames = nil
+			cn.pathParamConstraint = nil
+			cn.methodHandlers = &methodHandlers{}
+			cn.otherMethodHandlers = nil
+			cn.catchAllHandler = nil
+			cn.hasAtLeastOneHandler = false
+			cn.addChild(nn)
+
+			if ll == sl { // At current node
+				cn.typ = nt
+				cn.pathParamNames = pathParamNames
+				cn.setHandler(method, h)
+			} else { // Create child node
+				nn = &routeNode{
+					prefix:         s[ll:],
+					label:          s[ll],
+					typ:            nt,
+					parent:         cn,
+					staticChildren: make([]*routeNode, 255),
+					pathParamNames: pathParamNames,
+					methodHandlers: &methodHandlers{},
+				}
+
+				nn.setHandler(method, h)
+
+				cn.addChild(nn)
+			}
+		} else if ll < sl {
+			s = s[ll:]
+
+			nn, ppc = nil, nil
+			switch s[0] {
+			case ':':
+				ppc = pathParamConstraints[ppi]
+				nn = cn.paramChild(ppc)
+				ppi++
+			case '*':
+				nn = cn.wildcardParamChild
+				ppi++
+			default:
+				nn = cn.staticChildren[s[0]]
+			}
+
+			if nn != nil {
+				// Go deeper.
+				cn = nn
+				continue
+			}
+
+			// Create child node.
+			nn = &routeNode{
+				prefix:              s,
+				label:               s[0],
+				typ:                 nt,
+				parent:              cn,
+				staticChildren:      make([]*routeNode, 255),
+				pathParamNames:      pathParamNames,
+				pathParamConstraint: ppc,
+				methodHandlers:      &methodHandlers{},
+			}
+
+			nn.setHandler(method, h)
+
+			cn.addChild(nn)
+		} else if h != nil { // Node already exists
+			cn.setPathParamNames(method, pathParamNames)
+			cn.setHandler(method, h)
+		}
+
+		break
+	}
+}
+
+// Handler returns a matched [http.Handler] for the req along with a possible
+// revision of the req.
+//
+// The returned [http.Handler] is always non-nil.
+//
+// The revision of the req only happens when a route is matched (or the data
+// of the req is needed by the returned [http.Handler]) and the result of
+// req.Context() has nothing to do with the [Context]. Otherwise, the req itself
+// is returned.
+func (r *Router) Handler(req *http.Request) (http.Handler, *http.Request) {
+	h, req, _, _ := r.lookup(req, nil)
+	return h, req
+}
+
+// Match returns the result of matching the method and the path against the
+// registered routes without serving, using the same search as the
+// [Router.Handler]. Only routes registered without a Host are matched, and the
+// request being matched has neither headers nor a body.
+//
+// The path parameter values of the returned [MatchResult] are not pooled, so
+// it is safe to keep them.
+func (r *Router) Match(method, path string) *MatchResult {
+	return r.match(method, path, nil)
+}
+
+// Explain is like the [Router.Match], but returns a human-readable trace of
+// each step the search takes, such as the nodes visited, the path parameter
+// values captured and the backtracks, followed by the result. It is intended
+// for debugging, and its format may change in the future.
+func (r *Router) Explain(method, path string) string {
+	lt := &lookupTrace{}
+	mr := r.match(method, path, lt)
+
+	result := "result: " + mr.Status.String()
+	if mr.Pattern != "" {
+		result += " " + strconv.Quote(mr.Pattern)
+	}
+
+	for i, ppn := range mr.PathParamNames {
+		result += " " + ppn + "=" + strconv.Quote(mr.PathParamValues[i])
+	}
+
+	lt.add(result)
+
+	return strings.Join(lt.steps, "\n") + "\n"
+}
+
+// match is the implementation of the [Router.Match], which also records the
+// steps of the search into the lt if it is not nil.
+func (r *Router) match(method, path string, lt *lookupTrace) *MatchResult {
+	if r.Parent != nil {
+		return r.Parent.match(method, path, lt)
+	}
+
+	h, req, rn, ms := r.lookup((&http.Request{
+		Method: method,
+		URL:    &url.URL{Path: path},
+		Header: http.Header{},
+	}).WithContext(Context()), lt)
+
+	mr := &MatchResult{
+		Status:  ms,
+		Handler: h,
+	}
+
+	if rn == nil {
+		return mr
+	}
+
+	if ms != MatchFound {
+		mr.Pattern = rn.pattern(method)
+		return mr
+	}
+
+	d := req.Context().Value(dataContextKey).(*data)
+	mr.Pattern = rn.pattern(d.routeMethod)
+	if len(d.pathParamNames) > 0 {
+		mr.PathParamNames = d.pathParamNames
+		mr.PathParamValues = make([]string, len(d.pathParamNames))
+		copy(mr.PathParamValues, d.pathParamValues)
+
+		//lint:ignore SA6002 this is harmless
+		d.pathParamValuesPool.Put(d.pathParamValues)
+	}
+
+	return mr
+}
+
+// MatchResult is the result of the [Router.Match].
+type MatchResult struct {
+	// Status is the status of the match.
+	Status MatchStatus
+
+	// Handler is the [http.Handler] that the [Router.Handler] would return
+	// for the match. It is always non-nil.
+	Handler http.Handler
+
+	// Pattern is the path of the matched route, in which each path
+	// parameter is written as it is declared in the [Router.Handle]. For a
+	// route with optional path segments, it is the one of all the paths the
+	// route represents that is matched. It is empty unless the Status is
+	// [MatchFound] or [MatchMethodNotAllowed].
+	Pattern string
+
+	// PathParamNames is the path parameter names of the matched route. The
+	// name of a wildcard path parameter always starts with '*'. It is nil
+	// unless the Status is [MatchFound].
+	PathParamNames []string
+
+	// PathParamValues is the path parameter values of the matched route,
+	// which correspond to the PathParamNames. It is nil unless the Status
+	// is [MatchFound].
+	PathParamValues []string
+}
+
+// MatchStatus is the status of a [MatchResult].
+type MatchStatus uint8
+
+// The match statuses.
+const (
+	// MatchNotFound means that no route matches.
+	MatchNotFound MatchStatus = iota
+
+	// MatchFound means that a route matches.
+	MatchFound
+
+	// MatchMethodNotAllowed means that at least one route matches only the
+	// path but not the method.
+	MatchMethodNotAllowed
+
+	// MatchNotAcceptable means that at least one route matches except for
+	// its [Produces].
+	MatchNotAcceptable
+
+	// MatchUnsupportedMediaType means that at least one route matches
+	// except for its [Consumes].
+	MatchUnsupportedMediaType
+
+	// MatchTSR means that the path should be redirected to the one with a
+	// trailing slash. See the [Router.TSRHandler] for more details.
+	MatchTSR
+)
+
+// String returns the description of the ms.
+func (ms MatchStatus) String() string {
+	switch ms {
+	case MatchFound:
+		return "found"
+	case MatchMethodNotAllowed:
+		return "method not allowed"
+	case MatchNotAcceptable:
+		return "not acceptable"
+	case MatchUnsupportedMediaType:
+		return "unsupported media type"
+	case MatchTSR:
+		return "trailing slash redirect"
+	}
+
+	return "not found"
+}
+
+// lookup is the implementation of the [Router.Handler]. It also returns the
+// matched [routeNode] and the [MatchStatus], and records the steps of the
+// search into the lt if it is not nil.
+func (r *Router) lookup(
+	req *http.Request,
+	lt *lookupTrace,
+) (http.Handler, *http.Request, *routeNode, MatchStatus) {
+	if r.Parent != nil {
+		return r.Parent.lookup(req, lt)
+	}
+
+	// The data of the req may come from the [Context] and be left over
+	// from a previous request.
+	if d, ok := req.Context().Value(dataContextKey).(*data); ok {
+		d.reset()
+	}
+
+	tb := r.loadTable()
+	if tb.routeTree == nil {
+		if lt != nil {
+			lt.add("no routes registered")
+		}
+
+		return r.notFoundHandlerFor(tb, req), req, nil, MatchNotFound
+	}
+
+	var (
+		hrt  *hostRouteTree // Host route tree
+		hpvs []string       // Host parameter values
+		rt   = tb.routeTree // Route tree
+	)
+
+	if len(tb.hostRouteTrees) > 0 {
+		host := requestHost(req)
+		for _, t := range tb.hostRouteTrees {
+			if vs, ok := t.match(host); ok {
+				hrt, hpvs, rt = t, vs, t.routeTree
+				break
+			}
+		}
+	}
+
+	var (
+		s    = req.URL.Path // Search
+		si   int            // Search index
+		sl   int            // Search length
+		pl   int            // Prefix length
+		ll   int            // LCP length
+		ml   int            // Minimum length of the sl and pl
+		cn   = rt           // Current node
+		pn   *routeNode     // Previous node
+		nn   *routeNode     // Next node
+		sn   *routeNode     // Saved node
+		fnt  routeNodeType  // From node type
+		pci  int            // Parameter child index
+		pvl  int            // Path parameter value length
+		ppi  int            // Path parameter index
+		ppvs []string       // Path parameter values
+		i    int            // Index
+		h    http.Handler   // Handler
+		mfs  int            // Matching failure status
+		st   int            // Status
+		ah   bool           // Auto HEAD
+		ao   bool           // Auto OPTIONS
+		ca   bool           // Catch-all
+		rm   string         // Route method
+	)
+
+	// Node search order: static > parameter > wildcard parameter.
+OuterLoop:
+	for {
+		if cn.typ == staticRouteNode {
+			sl, pl = len(s), len(cn.prefix)
+			if sl < pl {
+				ml = sl
+			} else {
+				ml = pl
+			}
+
+			ll = 0
+			for ; ll < ml && s[ll] == cn.prefix[ll]; ll++ {
+			}
+
+			if ll != pl {
+				if lt != nil {
+					lt.add("static node " + lt.node(cn) +
+						" does not match " +
+						strconv.Quote(s))
+				}
+
+				fnt = staticRouteNode
+				goto BacktrackToPreviousNode
+			}
+
+			if lt != nil {
+				lt.add("static node " + lt.node(cn) +
+					" matches")
+			}
+
+			s = s[ll:]
+			si += ll
+		}
+
+		if s == "" && cn.hasAtLeastOneHandler {
+			if sn == nil {
+				sn = cn
+			}
+
+			switch req.Method {
+			case http.MethodGet:
+				h = cn.methodHandlers.get
+			case http.MethodHead:
+				h, ah = cn.methodHandlers.head, false
+			case http.MethodPost:
+				h = cn.methodHandlers.post
+			case http.MethodPut:
+				h = cn.methodHandlers.put
+			case http.MethodPatch:
+				h = cn.methodHandlers.patch
+			case http.MethodDelete:
+				h = cn.methodHandlers.delete
+			case http.MethodConnect:
+				h = cn.methodHandlers.connect
+			case http.MethodOptions:
+				h = cn.methodHandlers.options
+			case http.MethodTrace:
+				h = cn.methodHandlers.trace
+			default:
+				for _, omh := range cn.otherMethodHandlers {
+					if omh.method == req.Method {
+						h = omh.handler
+						break
+					}
+				}
+			}
+
+			if mh, ok := h.(*matchingHandler); ok {
+				h, st = mh.match(req)
+				if h == nil && sn == cn && st > mfs {
+					mfs = st
+				}
+
+				if h == nil && lt != nil {
+					lt.add("matchers of node " +
+						lt.node(cn) +
+						" reject the request with " +
+						"status " + strconv.Itoa(st))
+				}
+			}
+
+			ca = h == nil && cn.catchAllHandler != nil
+			if ca {
+				h, ah = cn.catchAllHandler.handler, false
+				if mh, ok := h.(*matchingHandler); ok {
+					h, st = mh.match(req)
+					if h == nil && sn == cn && st > mfs {
+						mfs = st
+					}
+
+					if h == nil && lt != nil {
+						lt.add("matchers of node " +
+							lt.node(cn) +
+							" reject the request " +
+							"with status " +
+							strconv.Itoa(st))
+					}
+				}
+			}
+
+			if h == nil &&
+				req.Method == http.MethodHead &&
+				r.AutoHEAD {
+				h, ah, ca = cn.methodHandlers.get, true, false
+				if mh, ok := h.(*matchingHandler); ok {
+					h, st = mh.match(req)
+					if h == nil && sn == cn && st > mfs {
+						mfs = st
+					}
+
+					if h == nil && lt != nil {
+						lt.add("matchers of node " +
+							lt.node(cn) +
+							" reject the request " +
+							"with status " +
+							strconv.Itoa(st))
+					}
+				}
+			}
+
+			if h == nil &&
+				req.Method == http.MethodOptions &&
+				r.AutoOPTIONS {
+				h, ao = r.autoOPTIONSHandler(), true
+			}
+
+			if lt != nil {
+				if h != nil {
+					lt.add("node " + lt.node(cn) +
+						" has handler for method " +
+						strconv.Quote(req.Method))
+				} else {
+					lt.add("node " + lt.node(cn) +
+						" has no handler for method " +
+						strconv.Quote(req.Method))
+				}
+			}
+
+			if h != nil {
+				break
+			}
+		}
+
+		// Try static node.
+		if s != "" && cn.staticChildren[s[0]] != nil {
+			cn = cn.staticChildren[s[0]]
+			continue OuterLoop
+		}
+
+		// Try parameter nodes.
+		pci, pvl = 0, -1
+	TryParamNode:
+		for ; pci < len(cn.paramChildren); pci, pvl = pci+1, -1 {
+			nn, sl = cn.paramChildren[pci], len(s)
+
+			if ppvs == nil {
+				ppvs = tb.pathParamValuesPool.Get().([]string)
+			}
+
+			// The path parameter value ends at the first '/' or
+			// the first character that any static child of the nn
+			// starts with. When retried, the latter moves to the
+			// next such character until the former is reached.
+			for pvl < sl && (pvl < 0 || s[pvl] != '/') {
+				for i = pvl + 1; i < sl; i++ {
+					if s[i] == '/' ||
+						nn.staticChildren[s[i]] != nil {
+						break
+					}
+				}
+
+				pvl = i
+				if nn.pathParamConstraint != nil &&
+					!nn.pathParamConstraint.match(s[:i]) {
+					if lt != nil {
+						lt.add("param node " +
+							lt.node(nn) +
+							" rejects " +
+							strconv.Quote(s[:i]))
+					}
+
+					continue
+				}
+
+				if lt != nil {
+					lt.add("param node " + lt.node(nn) +
+						" captures " +
+						strconv.Quote(s[:i]))
+				}
+
+				cn = nn
+
+				ppvs[ppi] = s[:i]
+				ppi++
+
+				s = s[i:]
+				si += i
+
+				continue OuterLoop
+			}
+		}
+
+		// Try wildcard parameter node.
+		pvl = -1
+	TryWildcardParamNode:
+		if nn = cn.wildcardParamChild; nn != nil && pvl < len(s) {
+			// The wildcard path parameter value first ends at the
+			// last '/' from which the children of the nn may
+			// continue, then moves to the previous '/' each time it
+			// is retried, and finally covers all the rest of the s.
+			i = -1
+			if nn.hasAtLeastOneChild {
+				if pvl < 0 {
+					pvl = len(s)
+				}
+
+				i = strings.LastIndexByte(s[:pvl], '/')
+			}
+
+			if i <= 0 {
+				i = len(s)
+			}
+
+			if lt != nil {
+				lt.add("wildcard node " + lt.node(nn) +
+					" captures " + strconv.Quote(s[:i]))
+			}
+
+			cn = nn
+
+			if ppvs == nil {
+				ppvs = tb.pathParamValuesPool.Get().([]string)
+			}
+
+			ppvs[ppi] = s[:i]
+			ppi++
+
+			s = s[i:]
+			si += i
+
+			continue
+		}
+
+		fnt = wildcardParamRouteNode
+
+		// Backtrack to previous node.
+	BacktrackToPreviousNode:
+		if fnt != staticRouteNode {
+			if cn.typ == staticRouteNode {
+				si -= len(cn.prefix)
+			} else {
+				ppi--
+				si -= len(ppvs[ppi])
+			}
+
+			s = req.URL.Path[si:]
+		}
+
+		if lt != nil && cn.parent != nil {
+			lt.add("backtrack from node " + lt.node(cn))
+		}
+
+		pn, cn = cn, cn.parent
+		if cn != nil {
+			switch pn.typ {
+			case staticRouteNode:
+				pci, pvl = 0, -1
+				goto TryParamNode
+			case paramRouteNode:
+				pci, pvl = 0, len(ppvs[ppi])
+				for cn.paramChildren[pci] != pn {
+					pci++
+				}
+
+				goto TryParamNode
+			case wildcardParamRouteNode:
+				pvl = len(ppvs[ppi])
+				goto TryWildcardParamNode
+			}
+		} else if fnt == staticRouteNode {
+			sn = nil
+		}
+
+		break
+	}
+
+	// The ppvs is only kept when the matched route has path parameters.
+	if ppvs != nil &&
+		(cn == nil || h == nil || len(cn.pathParamNames) == 0) {
+		//lint:ignore SA6002 this is harmless
+		tb.pathParamValuesPool.Put(ppvs)
+	}
+
+	if cn == nil || h == nil {
+		switch mfs {
+		case http.StatusNotAcceptable:
+			return r.notAcceptableHandler(),
+				req,
+				nil,
+				MatchNotAcceptable
+		case http.StatusUnsupportedMediaType:
+			return r.unsupportedMediaTypeHandler(),
+				req,
+				nil,
+				MatchUnsupportedMediaType
+		}
+
+		if sn != nil && sn.hasAtLeastOneHandler && mfs == 0 {
+			var d *data
+			req, d = requestData(req)
+			d.allowedMethods = r.allowedMethods(sn)
+			return r.methodNotAllowedHandler(),
+				req,
+				sn,
+				MatchMethodNotAllowed
+		}
+
+		return r.notFoundHandlerFor(tb, req), req, nil, MatchNotFound
+	}
+
+	switch {
+	case ah:
+		rm = http.MethodGet
+	case ao:
+		rm = http.MethodOptions
+	case ca:
+		rm = cn.catchAllHandler.method
+		if rm == "_tsr" {
+			cn = nil // TSR responses are not for any route
+		}
+	default:
+		rm = req.Method
+	}
+
+	// Attaching a data to the req is costly, so it is skipped for routes
+	// without any path or host parameters unless the req already has one,
+	// such as the one of the [Context].
+	if cn != nil && len(cn.pathParamNames) > 0 || len(hpvs) > 0 || ao {
+		req, _ = requestData(req)
+	}
+
+	if d, ok := req.Context().Value(dataContextKey).(*data); ok {
+		if cn != nil {
+			d.routeNode = cn
+			d.routeMethod = rm
+		}
+
+		if cn != nil && len(cn.pathParamNames) > 0 {
+			d.pathParamNames = cn.pathParamNamesFor(rm)
+			d.pathParamValues = ppvs
+			d.pathParamValuesPool = tb.pathParamValuesPool
+		}
+
+		if len(hpvs) > 0 {
+			d.hostParamNames = hrt.hostParamNames
+			d.hostParamValues = hpvs
+		}
+
+		if ao {
+			d.allowedMethods = r.allowedMethods(cn)
+		}
+	}
+
+	if ah {
+		gh := h
+		h = http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			hrw := &headResponseWriter{ResponseWriter: rw}
+			gh.ServeHTTP(hrw, req)
+			hrw.writeHeader()
+		})
+	}
+
+	if cn == nil {
+		return h, req, nil, MatchTSR
+	}
+
+	return h, req, cn, MatchFound
+}
+
+// ServeHTTP implements the [http.Handler].
+func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if r.Parent != nil {
+		r.Parent.ServeHTTP(rw, req)
+		return
+	}
+
+	h, req := r.Handler(req)
+	h.ServeHTTP(rw, req)
+}
+
+// notFoundHandlerFor returns the [http.Handler] of the first SPA fallback of
+// the tb that serves the req (see the [Router.ServeSPA]), or the result of the
+// [Router.notFoundHandler] if none.
+func (r *Router) notFoundHandlerFor(
+	tb *routeTable,
+	req *http.Request,
+) http.Handler {
+	for _, sf := range tb.spaFallbacks {
+		if sf.serves(req) {
+			return sf.handler
+		}
+	}
+
+	return r.notFoundHandler()
+}
+
+// notFoundHandler returns an [http.Handler] to write not found responses.
+func (r *Router) notFoundHandler() http.Handler {
+	r.chainHandlers()
+	return r.chainedNotFoundHandler
+}
+
+// newNotFoundHandler returns a new [http.Handler] chained with the
+// r.Middlewares to write not found responses.
+func (r *Router) newNotFoundHandler() http.Handler {
+	h := r.NotFoundHandler
+	if h == nil {
+		h = http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			http.Error(
+				rw,
+				http.StatusText(http.StatusNotFound),
+				http.StatusNotFound,
+			)
+		})
+	}
+
+	return r.chain(h)
+}
+
+// methodNotAllowedHandler returns an [http.Handler] to write method not allowed
+// responses.
+func (r *Router) methodNotAllowedHandler() http.Handler {
+	r.chainHandlers()
+	return r.chainedMethodNotAllowedHandler
+}
+
+// newMethodNotAllowedHandler returns a new [http.Handler] chained with the
+// r.Middlewares to write method not allowed responses.
+func (r *Router) newMethodNotAllowedHandler() http.Handler {
+	h := r.MethodNotAllowedHandler
+	if h == nil {
+		h = http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			rw.Header().Set(
+				"Allow",
+				strings.Join(AllowedMethods(req), ", "),
+			)
+			http.Error(
+				rw,
+				http.StatusText(http.StatusMethodNotAllowed),
+				http.StatusMethodNotAllowed,
+			)
+		})
+	}
+
+	return r.chain(h)
+}
+
+// notAcceptableHandler returns an [http.Handler] to write not acceptable
+// responses.
+func (r *Router) notAcceptableHandler() http.Handler {
+	r.chainHandlers()
+	return r.chainedNotAcceptableHandler
+}
+
+// newNotAcceptableHandler returns a new [http.Handler] chained with the
+// r.Middlewares to write not acceptable responses.
+func (r *Router) newNotAcceptableHandler() http.Handler {
+	h := r.NotAcceptableHandler
+	if h == nil {
+		h = http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			http.Error(
+				rw,
+				http.StatusText(http.StatusNotAcceptable),
+				http.StatusNotAcceptable,
+			)
+		})
+	}
+
+	return r.chain(h)
+}
+
+// unsupportedMediaTypeHandler returns an [http.Handler] to write unsupported
+// media type responses.
+func (r *Router) unsupportedMediaTypeHandler() http.Handler {
+	r.chainHandlers()
+	return r.chainedUnsupportedMediaTypeHandler
+}
+
+// newUnsupportedMediaTypeHandler returns a new [http.Handler] chained with
+// the r.Middlewares to write unsupported media type responses.
+func (r *Router) newUnsupportedMediaTypeHandler() http.Handler {
+	h := r.UnsupportedMediaTypeHandler
+	if h == nil {
+		h = http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			status := http.StatusUnsupportedMediaType
+			http.Error(rw, http.StatusText(status), status)
+		})
+	}
+
+	return r.chain(h)
+}
+
+// autoOPTIONSHandler returns an [http.Handler] to write automatic OPTIONS
+// responses.
+func (r *Router) autoOPTIONSHandler() http.Handler {
+	r.chainHandlers()
+	return r.chainedAutoOPTIONSHandler
+}
+
+// newAutoOPTIONSHandler returns a new [http.Handler] chained with the
+// r.Middlewares to write automatic OPTIONS responses.
+func (r *Router) newAutoOPTIONSHandler() http.Handler {
+	var h http.Handler = http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		rw.Header().Set(
+			"Allow",
+			strings.Join(AllowedMethods(req), ", "),
+		)
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	return r.chain(h)
+}
+
+// allowedMethods returns the methods allowed by the rn.
+func (r *Router) allowedMethods(rn *routeNode) []string {
+	all := rn.catchAllHandler != nil && rn.catchAllHandler.method == ""
+	mhs := rn.methodHandlers
+
+	var ams []string
+	if all || mhs.get != nil {
+		ams = append(ams, http.MethodGet)
+	}
+
+	if all || mhs.head != nil || r.AutoHEAD && mhs.get != nil {
+		ams = append(ams, http.MethodHead)
+	}
+
+	if all || mhs.post != nil {
+		ams = append(ams, http.MethodPost)
+	}
+
+	if all || mhs.put != nil {
+		ams = append(ams, http.MethodPut)
+	}
+
+	if all || mhs.patch != nil {
+		ams = append(ams, http.MethodPatch)
+	}
+
+	if all || mhs.delete != nil {
+		ams = append(ams, http.MethodDelete)
+	}
+
+	if all || mhs.connect != nil {
+		ams = append(ams, http.MethodConnect)
+	}
+
+	if all || mhs.options != nil || r.AutoOPTIONS {
+		ams = append(ams, http.MethodOptions)
+	}
+
+	if all || mhs.trace != nil {
+		ams = append(ams, http.MethodTrace)
+	}
+
+	for _, omh := range rn.otherMethodHandlers {
+		ams = append(ams, omh.method)
+	}
+
+	return ams
+}
+
+// tsrHandler returns an [http.Handler] to write TSR (Trailing Slash Redirect)
+// responses.
+func (r *Router) tsrHandler() http.Handler {
+	r.chainHandlers()
+	return r.chainedTSRHandler
+}
+
+// newTSRHandler returns a new [http.Handler] chained with the r.Middlewares
+// to write TSR (Trailing Slash Redirect) responses.
+func (r *Router) newTSRHandler() http.Handler {
+	h := r.TSRHandler
+	if h == nil {
+		h = http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			requestURI := req.RequestURI
+			if requestURI == "" {
+				requestURI = "/"
+			} else {
+				path, query := requestURI, ""
+				for i := 0; i < len(path); i++ {
+					if path[i] == '?' {
+						query = path[i:]
+						path = path[:i]
+						break
+					}
+				}
+
+				if path == "" || path[len(path)-1] != '/' {
+					path += "/"
+				}
+
+				requestURI = path + query
+			}
+
+			http.Redirect(
+				rw,
+				req,
+				requestURI,
+				http.StatusMovedPermanently,
+			)
+		})
+	}
+
+	return r.chain(h)
+}
+
+// chainHandlers builds the chained builtin handlers of the r once, so that
+// they can be safely used while routes are being registered.
+func (r *Router) chainHandlers() {
+	r.chainHandlersOnce.Do(func() {
+		r.chainedNotFoundHandler = r.newNotFoundHandler()
+		r.chainedMethodNotAllowedHandler =
+			r.newMethodNotAllowedHandler()
+		r.chainedNotAcceptableHandler = r.newNotAcceptableHandler()
+		r.chainedUnsupportedMediaTypeHandler =
+			r.newUnsupportedMediaTypeHandler()
+		r.chainedTSRHandler = r.newTSRHandler()
+		r.chainedAutoOPTIONSHandler = r.newAutoOPTIONSHandler()
+	})
+}
+
+// chain returns the h chained with the r.Middlewares.
+func (r *Router) chain(h http.Handler) http.Handler {
+	for i := len(r.Middlewares) - 1; i >= 0; i-- {
+		if r.Middlewares[i] != nil {
+			h = r.Middlewares[i].ChainHTTPHandler(h)
+		}
+	}
+
+	return h
+}
+
+// lookupTrace records the steps of the [Router.lookup].
+type lookupTrace struct {
+	steps []string
+}
+
+// add adds the step to the lt.
+func (lt *lookupTrace) add(step string) {
+	lt.steps = append(lt.steps, step)
+}
+
+// node returns the description of the rn used in the steps of the lt.
+func (lt *lookupTrace) node(rn *routeNode) string {
+	return strconv.Quote(rn.prefix) + " " + rn.describe()[1]
+}
+
+// routeTable is a snapshot of the registered routes of a [Router].
+type routeTable struct {
+	routeTree           *routeNode
+	hostRouteTrees      []*hostRouteTree
+	registeredRoutes    map[string]bool
+	namedRoutes         map[string][]*urlPattern
+	routes              []Route
+	maxPathParams       int
+	pathParamValuesPool *sync.Pool
+	spaFallbacks        []*spaFallback
+}
 
-			cn.addChild(nn)
-		} else if h != nil { // Node already exists
-			if len(cn.pathParamNames) == 0 {
-				cn.pathParamNames = pathParamNames
-			}
+// loadTable returns the current [routeTable] of the r, which must not be
+// modified unless the r.tableMutex is held and the r.Concurrent is false.
+func (r *Router) loadTable() *routeTable {
+	if tb, ok := r.table.Load().(*routeTable); ok {
+		return tb
+	}
 
-			cn.setHandler(method, h)
-		}
+	return &routeTable{}
+}
 
-		break
+// writableTable returns a [routeTable] of the r that can be modified, which is
+// either the current one or a copy of it based on the r.Concurrent. It must be
+// called with the r.tableMutex held, and the result should be stored into the
+// r.table once modified.
+func (r *Router) writableTable() *routeTable {
+	tb, ok := r.table.Load().(*routeTable)
+	if !ok {
+		return &routeTable{}
+	} else if r.Concurrent {
+		return tb.clone()
 	}
+
+	return tb
 }
 
-// Handler returns a matched [http.Handler] for the req along with a possible
-// revision of the req.
-//
-// The returned [http.Handler] is always non-nil.
-//
-// The revision of the req only happens when the matched route has at least one
-// path parameter and the result of req.Context() has nothing to do with the
-// [Context]. Otherwise, the req itself is returned.
-func (r *Router) Handler(req *http.Request) (http.Handler, *http.Request) {
-	if r.Parent != nil {
-		return r.Parent.Handler(req)
+// clone returns a deep copy of the tb that can be modified without affecting
+// the tb.
+func (tb *routeTable) clone() *routeTable {
+	c := &routeTable{
+		registeredRoutes:    map[string]bool{},
+		namedRoutes:         map[string][]*urlPattern{},
+		routes:              tb.routes[:len(tb.routes):len(tb.routes)],
+		maxPathParams:       tb.maxPathParams,
+		pathParamValuesPool: tb.pathParamValuesPool,
+		spaFallbacks:        tb.spaFallbacks,
 	}
 
-	if r.routeTree == nil {
-		return r.notFoundHandler(), req
+	if tb.routeTree != nil {
+		c.routeTree = tb.routeTree.clone(nil)
 	}
 
-	var (
-		s    = req.URL.Path // Search
-		si   int            // Search index
-		sl   int            // Search length
-		pl   int            // Prefix length
-		ll   int            // LCP length
-		ml   int            // Minimum length of the sl and pl
-		cn   = r.routeTree  // Current node
-		sn   *routeNode     // Saved node
-		fnt  routeNodeType  // From node type
-		nnt  routeNodeType  // Next node type
-		ppi  int            // Path parameter index
-		ppvs []string       // Path parameter values
-		i    int            // Index
-		h    http.Handler   // Handler
-	)
+	for _, hrt := range tb.hostRouteTrees {
+		chrt := *hrt
+		chrt.routeTree = hrt.routeTree.clone(nil)
+		c.hostRouteTrees = append(c.hostRouteTrees, &chrt)
+	}
 
-	// Node search order: static > parameter > wildcard parameter.
-OuterLoop:
-	for {
-		if cn.typ == staticRouteNode {
-			sl, pl = len(s), len(cn.prefix)
-			if sl < pl {
-				ml = sl
-			} else {
-				ml = pl
-			}
+	for k, v := range tb.registeredRoutes {
+		c.registeredRoutes[k] = v
+	}
 
-			ll = 0
-			for ; ll < ml && s[ll] == cn.prefix[ll]; ll++ {
-			}
+	for k, v := range tb.namedRoutes {
+		c.namedRoutes[k] = v[:len(v):len(v)]
+	}
 
-			if ll != pl {
-				fnt = staticRouteNode
-				goto BacktrackToPreviousNode
-			}
+	return c
+}
 
-			s = s[ll:]
-			si += ll
-		}
+// hostRouteTree is a route radix tree for a host.
+type hostRouteTree struct {
+	host           string
+	labels         []string
+	hostParamNames []string
+	routeTree      *routeNode
+}
 
-		if s == "" && cn.hasAtLeastOneHandler {
-			if sn == nil {
-				sn = cn
+// newHostRouteTree returns a new instance of the [hostRouteTree] for the host.
+func newHostRouteTree(host string) (*hostRouteTree, *RouteError) {
+	hrt := &hostRouteTree{
+		host:   host,
+		labels: strings.Split(host, "."),
+		routeTree: &routeNode{
+			staticChildren: make([]*routeNode, 255),
+			methodHandlers: &methodHandlers{},
+		},
+	}
+
+	for _, label := range hrt.labels {
+		if label == "" {
+			return nil, &RouteError{
+				Err:    ErrInvalidRouteHost,
+				Detail: "route host label cannot be empty",
 			}
+		}
 
-			switch req.Method {
-			case http.MethodGet:
-				h = cn.methodHandlers.get
-			case http.MethodHead:
-				h = cn.methodHandlers.head
-			case http.MethodPost:
-				h = cn.methodHandlers.post
-			case http.MethodPut:
-				h = cn.methodHandlers.put
-			case http.MethodPatch:
-				h = cn.methodHandlers.patch
-			case http.MethodDelete:
-				h = cn.methodHandlers.delete
-			case http.MethodConnect:
-				h = cn.methodHandlers.connect
-			case http.MethodOptions:
-				h = cn.methodHandlers.options
-			case http.MethodTrace:
-				h = cn.methodHandlers.trace
-			default:
-				for _, omh := range cn.otherMethodHandlers {
-					if omh.method == req.Method {
-						h = omh.handler
-						break OuterLoop
-					}
+		if label[0] != '{' || label[len(label)-1] != '}' {
+			if strings.ContainsAny(label, "{}") {
+				return nil, &RouteError{
+					Err: ErrInvalidRouteHost,
+					Detail: "route host parameter must " +
+						"be a whole label",
 				}
 			}
 
-			if h == nil && cn.catchAllHandler != nil {
-				h = cn.catchAllHandler.handler
-			}
+			continue
+		}
 
-			if h != nil {
-				break
+		hostParamName := label[1 : len(label)-1]
+		if hostParamName == "" {
+			return nil, &RouteError{
+				Err: ErrInvalidRouteHost,
+				Detail: "route host parameter name cannot be " +
+					"empty",
 			}
 		}
 
-		// Try static node.
-		if s != "" && cn.staticChildren[s[0]] != nil {
-			cn = cn.staticChildren[s[0]]
-			continue OuterLoop
+		for i := 0; i < len(hostParamName); i++ {
+			if !isPathParamNameByte(hostParamName[i]) {
+				return nil, &RouteError{
+					Err: ErrInvalidRouteHost,
+					Detail: "route host parameter name " +
+						"must consist of letters, " +
+						"digits and '_'",
+				}
+			}
 		}
 
-		// Try parameter node.
-	TryParamNode:
-		if cn.paramChild != nil {
-			cn = cn.paramChild
-
-			i, sl = 0, len(s)
-			for ; i < sl && s[i] != '/'; i++ {
+		for _, hpn := range hrt.hostParamNames {
+			if hpn == hostParamName {
+				return nil, &RouteError{
+					Err: ErrInvalidRouteHost,
+					Detail: "route host cannot have " +
+						"duplicate parameter names",
+				}
 			}
+		}
 
-			if ppvs == nil {
-				ppvs = r.pathParamValuesPool.Get().([]string)
-			}
+		hrt.hostParamNames = append(hrt.hostParamNames, hostParamName)
+	}
 
-			ppvs[ppi] = s[:i]
-			ppi++
+	return hrt, nil
+}
 
-			s = s[i:]
-			si += i
+// match reports whether the host matches the hrt, along with the values of the
+// host parameters of the hrt.
+func (hrt *hostRouteTree) match(host string) ([]string, bool) {
+	var (
+		hpvs []string
+		hl   string
+	)
 
-			continue
+	for i, label := range hrt.labels {
+		if i == len(hrt.labels)-1 {
+			hl, host = host, ""
+		} else if j := strings.IndexByte(host, '.'); j >= 0 {
+			hl, host = host[:j], host[j+1:]
+		} else {
+			return nil, false
 		}
 
-		// Try wildcard parameter node.
-	TryWildcardParamNode:
-		if cn.wildcardParamChild != nil {
-			cn = cn.wildcardParamChild
-
-			if ppvs == nil {
-				ppvs = r.pathParamValuesPool.Get().([]string)
+		if label[0] == '{' {
+			if hl == "" || strings.IndexByte(hl, '.') >= 0 {
+				return nil, false
 			}
 
-			ppvs[ppi] = s
-			ppi++
+			hpvs = append(hpvs, hl)
+		} else if hl != label {
+			return nil, false
+		}
+	}
 
-			si += len(s)
-			s = ""
+	return hpvs, true
+}
 
-			if sn == nil {
-				sn = cn
-			}
+// requestHost returns the host of the req in lower case and without its port.
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if i := strings.LastIndexByte(host, ':'); i >= 0 &&
+		strings.IndexByte(host[i:], ']') < 0 {
+		host = host[:i]
+	}
 
-			switch req.Method {
-			case http.MethodGet:
-				h = cn.methodHandlers.get
-			case http.MethodHead:
-				h = cn.methodHandlers.head
-			case http.MethodPost:
-				h = cn.methodHandlers.post
-			case http.MethodPut:
-				h = cn.methodHandlers.put
-			case http.MethodPatch:
-				h = cn.methodHandlers.patch
-			case http.MethodDelete:
-				h = cn.methodHandlers.delete
-			case http.MethodConnect:
-				h = cn.methodHandlers.connect
-			case http.MethodOptions:
-				h = cn.methodHandlers.options
-			case http.MethodTrace:
-				h = cn.methodHandlers.trace
-			default:
-				for _, omh := range cn.otherMethodHandlers {
-					if omh.method == req.Method {
-						h = omh.handler
-						break OuterLoop
-					}
-				}
-			}
+	return strings.ToLower(host)
+}
 
-			if h == nil && cn.catchAllHandler != nil {
-				h = cn.catchAllHandler.handler
-			}
+// routeNode is a node of a route radix tree.
+type routeNode struct {
+	prefix               string
+	label                byte
+	typ                  routeNodeType
+	parent               *routeNode
+	staticChildren       []*routeNode
+	paramChildren        []*routeNode
+	wildcardParamChild   *routeNode
+	hasAtLeastOneChild   bool
+	pathParamNames       []string
+	methodPathParamNames map[string][]string
+	pathParamConstraint  *pathParamConstraint
+	methodHandlers       *methodHandlers
+	otherMethodHandlers  []*methodHandler
+	catchAllHandler      *methodHandler
+	hasAtLeastOneHandler bool
+}
 
-			if h != nil {
-				break
+// addChild adds the n as a child node to the rn.
+func (rn *routeNode) addChild(n *routeNode) {
+	switch n.typ {
+	case staticRouteNode:
+		rn.staticChildren[n.label] = n
+	case paramRouteNode:
+		i := len(rn.paramChildren)
+		if n.pathParamConstraint != nil {
+			// Constrained parameter nodes always take precedence
+			// over the unconstrained one.
+			for ; i > 0; i-- {
+				c := rn.paramChildren[i-1]
+				if c.pathParamConstraint != nil {
+					break
+				}
 			}
 		}
 
-		fnt = wildcardParamRouteNode
+		rn.paramChildren = append(rn.paramChildren, nil)
+		copy(rn.paramChildren[i+1:], rn.paramChildren[i:])
+		rn.paramChildren[i] = n
+	case wildcardParamRouteNode:
+		rn.wildcardParamChild = n
+	}
 
-		// Backtrack to previous node.
-	BacktrackToPreviousNode:
-		if fnt != staticRouteNode {
-			if cn.typ == staticRouteNode {
-				si -= len(cn.prefix)
-			} else {
-				ppi--
-				si -= len(ppvs[ppi])
-			}
+	rn.hasAtLeastOneChild = true
+}
 
-			s = req.URL.Path[si:]
-		}
+// pattern returns the path of the route that ends at the rn for the method, in
+// which each path parameter is written as it is declared in the
+// [Router.Handle]. It returns empty string if the rn does not know the names of
+// all those path parameters, which happens when no route ends at it.
+func (rn *routeNode) pattern(method string) string {
+	var ns []*routeNode
+	for n := rn; n != nil; n = n.parent {
+		ns = append(ns, n)
+	}
 
-		if cn.typ < wildcardParamRouteNode {
-			nnt = cn.typ + 1
-		} else {
-			nnt = staticRouteNode
+	var (
+		ppns = rn.pathParamNamesFor(method)
+		b    strings.Builder
+		ppi  int
+	)
+	for i := len(ns) - 1; i >= 0; i-- {
+		if n := ns[i]; n.typ != staticRouteNode && ppi == len(ppns) {
+			return ""
 		}
 
-		cn = cn.parent
-		if cn != nil {
-			switch nnt {
-			case paramRouteNode:
-				goto TryParamNode
-			case wildcardParamRouteNode:
-				goto TryWildcardParamNode
+		switch n := ns[i]; n.typ {
+		case paramRouteNode:
+			b.WriteString(":" + ppns[ppi])
+			if n.pathParamConstraint != nil {
+				b.WriteString(n.pathParamConstraint.key)
 			}
-		} else if fnt == staticRouteNode {
-			sn = nil
-		}
 
-		break
+			ppi++
+		case wildcardParamRouteNode:
+			b.WriteString(ppns[ppi])
+			ppi++
+		default:
+			b.WriteString(n.prefix)
+		}
 	}
 
-	if cn == nil || h == nil {
-		if ppvs != nil {
-			//lint:ignore SA6002 this is harmless
-			r.pathParamValuesPool.Put(ppvs)
+	return b.String()
+}
+
+// clone returns a deep copy of the rn whose parent is the parent.
+func (rn *routeNode) clone(parent *routeNode) *routeNode {
+	c := *rn
+	c.parent = parent
+	c.staticChildren = make([]*routeNode, len(rn.staticChildren))
+	for i, sc := range rn.staticChildren {
+		if sc != nil {
+			c.staticChildren[i] = sc.clone(&c)
 		}
+	}
+
+	c.paramChildren = nil
+	for _, pc := range rn.paramChildren {
+		c.paramChildren = append(c.paramChildren, pc.clone(&c))
+	}
 
-		if sn != nil && sn.hasAtLeastOneHandler {
-			return r.methodNotAllowedHandler(), req
+	if rn.wildcardParamChild != nil {
+		c.wildcardParamChild = rn.wildcardParamChild.clone(&c)
+	}
+
+	c.methodPathParamNames = nil
+	for m, ppns := range rn.methodPathParamNames {
+		if c.methodPathParamNames == nil {
+			c.methodPathParamNames = map[string][]string{}
 		}
 
-		return r.notFoundHandler(), req
+		c.methodPathParamNames[m] = ppns
 	}
 
-	if len(cn.pathParamNames) > 0 {
-		if d, ok := req.Context().Value(dataContextKey).(*data); ok {
-			d.pathParamNames = cn.pathParamNames
-			d.pathParamValues = ppvs
-		} else {
-			req = req.WithContext(context.WithValue(
-				req.Context(),
-				dataContextKey,
-				&data{
-					pathParamNames:  cn.pathParamNames,
-					pathParamValues: ppvs,
-				},
-			))
+	mhs := *rn.methodHandlers
+	c.methodHandlers = &mhs
+
+	c.otherMethodHandlers = nil
+	for _, omh := range rn.otherMethodHandlers {
+		comh := *omh
+		c.otherMethodHandlers = append(c.otherMethodHandlers, &comh)
+	}
+
+	if rn.catchAllHandler != nil {
+		cah := *rn.catchAllHandler
+		c.catchAllHandler = &cah
+	}
+
+	return &c
+}
+
+// removeChild removes the n, which must be a child node of the rn, from the rn.
+func (rn *routeNode) removeChild(n *routeNode) {
+	switch n.typ {
+	case staticRouteNode:
+		rn.staticChildren[n.label] = nil
+	case paramRouteNode:
+		for i, c := range rn.paramChildren {
+			if c == n {
+				rn.paramChildren = append(
+					rn.paramChildren[:i:i],
+					rn.paramChildren[i+1:]...,
+				)
+				break
+			}
 		}
+	case wildcardParamRouteNode:
+		rn.wildcardParamChild = nil
 	}
 
-	return h, req
+	rn.hasAtLeastOneChild = len(rn.children()) > 0
 }
 
-// ServeHTTP implements the [http.Handler].
-func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if r.Parent != nil {
-		r.Parent.ServeHTTP(rw, req)
+// prune removes the rn from the route radix tree if it has neither handlers
+// nor child nodes, and then does the same to its ancestors. The deepest
+// remaining node is merged with its only child node if both of them are static
+// and it has no handlers, or reset if it is an empty root node.
+func (rn *routeNode) prune() {
+	n := rn
+	for n.parent != nil &&
+		!n.hasAtLeastOneHandler &&
+		!n.hasAtLeastOneChild {
+		n.parent.removeChild(n)
+		n = n.parent
+	}
+
+	if n.parent == nil &&
+		!n.hasAtLeastOneHandler &&
+		!n.hasAtLeastOneChild {
+		*n = routeNode{
+			staticChildren: make([]*routeNode, 255),
+			methodHandlers: &methodHandlers{},
+		}
+
+		return
+	}
+
+	if n.typ != staticRouteNode || n.hasAtLeastOneHandler {
+		return
+	}
+
+	children := n.children()
+	if len(children) != 1 || children[0].typ != staticRouteNode {
 		return
 	}
 
-	h, req := r.Handler(req)
-	h.ServeHTTP(rw, req)
+	c := children[0]
+	n.prefix += c.prefix
+	n.staticChildren = c.staticChildren
+	n.paramChildren = c.paramChildren
+	n.wildcardParamChild = c.wildcardParamChild
+	n.hasAtLeastOneChild = c.hasAtLeastOneChild
+	n.pathParamNames = c.pathParamNames
+	n.methodPathParamNames = c.methodPathParamNames
+	n.methodHandlers = c.methodHandlers
+	n.otherMethodHandlers = c.otherMethodHandlers
+	n.catchAllHandler = c.catchAllHandler
+	n.hasAtLeastOneHandler = c.hasAtLeastOneHandler
+	for _, gc := range n.children() {
+		gc.parent = n
+	}
 }
 
-// notFoundHandler returns an [http.Handler] to write not found responses.
-func (r *Router) notFoundHandler() http.Handler {
-	if r.chainedNotFoundHandler != nil {
-		return r.chainedNotFoundHandler
-	}
+// node returns the node of the rn that ends at the path, which must be the
+// path of a [routePath] along with its ppcs. It returns nil if not found.
+func (rn *routeNode) node(path string, ppcs []*pathParamConstraint) *routeNode {
+	var ppi int
+	for n := rn; n != nil; {
+		if !strings.HasPrefix(path, n.prefix) {
+			return nil
+		}
 
-	h := r.NotFoundHandler
-	if h == nil {
-		h = http.HandlerFunc(func(
-			rw http.ResponseWriter,
-			req *http.Request,
-		) {
-			http.Error(
-				rw,
-				http.StatusText(http.StatusNotFound),
-				http.StatusNotFound,
-			)
-		})
+		if path = path[len(n.prefix):]; path == "" {
+			return n
+		}
+
+		switch path[0] {
+		case ':':
+			n = n.paramChild(ppcs[ppi])
+			ppi++
+		case '*':
+			n = n.wildcardParamChild
+			ppi++
+		default:
+			n = n.staticChildren[path[0]]
+		}
 	}
 
-	if len(r.Middlewares) > 0 {
-		for i := len(r.Middlewares) - 1; i >= 0; i-- {
-			if r.Middlewares[i] != nil {
-				h = r.Middlewares[i].ChainHTTPHandler(h)
+	return nil
+}
+
+// paramChild returns the parameter child node of the rn that has the ppc. It
+// returns nil if not found.
+func (rn *routeNode) paramChild(ppc *pathParamConstraint) *routeNode {
+	for _, n := range rn.paramChildren {
+		if n.pathParamConstraint == nil || ppc == nil {
+			if n.pathParamConstraint == ppc {
+				return n
 			}
+		} else if n.pathParamConstraint.key == ppc.key {
+			return n
 		}
 	}
 
-	r.chainedNotFoundHandler = h
-
-	return h
+	return nil
 }
 
-// methodNotAllowedHandler returns an [http.Handler] to write method not allowed
-// responses.
-func (r *Router) methodNotAllowedHandler() http.Handler {
-	if r.chainedMethodNotAllowedHandler != nil {
-		return r.chainedMethodNotAllowedHandler
-	}
+// removeHandler removes the handler of the rn for the method, and reports
+// whether it exists.
+func (rn *routeNode) removeHandler(method string) bool {
+	var (
+		mhs = rn.methodHandlers
+		h   *http.Handler
+	)
+	switch method {
+	case "", "_tsr":
+		if rn.catchAllHandler == nil ||
+			rn.catchAllHandler.method != method {
+			return false
+		}
 
-	h := r.MethodNotAllowedHandler
-	if h == nil {
-		h = http.HandlerFunc(func(
-			rw http.ResponseWriter,
-			req *http.Request,
-		) {
-			http.Error(
-				rw,
-				http.StatusText(http.StatusMethodNotAllowed),
-				http.StatusMethodNotAllowed,
-			)
-		})
+		rn.catchAllHandler = nil
+	case http.MethodGet:
+		h = &mhs.get
+	case http.MethodHead:
+		h = &mhs.head
+	case http.MethodPost:
+		h = &mhs.post
+	case http.MethodPut:
+		h = &mhs.put
+	case http.MethodPatch:
+		h = &mhs.patch
+	case http.MethodDelete:
+		h = &mhs.delete
+	case http.MethodConnect:
+		h = &mhs.connect
+	case http.MethodOptions:
+		h = &mhs.options
+	case http.MethodTrace:
+		h = &mhs.trace
+	default:
+		for _, mh := range rn.otherMethodHandlers {
+			if mh.method == method {
+				rn.setHandler(method, nil)
+				delete(rn.methodPathParamNames, method)
+				return true
+			}
+		}
+
+		return false
 	}
 
-	if len(r.Middlewares) > 0 {
-		for i := len(r.Middlewares) - 1; i >= 0; i-- {
-			if r.Middlewares[i] != nil {
-				h = r.Middlewares[i].ChainHTTPHandler(h)
-			}
+	if h != nil {
+		if *h == nil {
+			return false
 		}
+
+		*h = nil
 	}
 
-	r.chainedMethodNotAllowedHandler = h
+	delete(rn.methodPathParamNames, method)
+	rn.hasAtLeastOneHandler = rn.hasMethodHandler() ||
+		rn.hasCatchAllHandler()
 
-	return h
+	return true
 }
 
-// tsrHandler returns an [http.Handler] to write TSR (Trailing Slash Redirect)
-// responses.
-func (r *Router) tsrHandler() http.Handler {
-	if r.chainedTSRHandler != nil {
-		return r.chainedTSRHandler
+// hasHandler reports whether the rn has a handler for the method.
+func (rn *routeNode) hasHandler(method string) bool {
+	if method == "" {
+		method = "*"
 	}
 
-	h := r.TSRHandler
-	if h == nil {
-		h = http.HandlerFunc(func(
-			rw http.ResponseWriter,
-			req *http.Request,
-		) {
-			requestURI := req.RequestURI
-			if requestURI == "" {
-				requestURI = "/"
-			} else {
-				path, query := requestURI, ""
-				for i := 0; i < len(path); i++ {
-					if path[i] == '?' {
-						query = path[i:]
-						path = path[:i]
-						break
-					}
-				}
+	for _, m := range rn.methods() {
+		if m == method {
+			return true
+		}
+	}
 
-				if path == "" || path[len(path)-1] != '/' {
-					path += "/"
-				}
+	return false
+}
 
-				requestURI = path + query
-			}
+// hasMethodHandler reports whether the rn has at least one handler for a
+// specific method.
+func (rn *routeNode) hasMethodHandler() bool {
+	return rn.methodHandlers.get != nil ||
+		rn.methodHandlers.head != nil ||
+		rn.methodHandlers.post != nil ||
+		rn.methodHandlers.put != nil ||
+		rn.methodHandlers.patch != nil ||
+		rn.methodHandlers.delete != nil ||
+		rn.methodHandlers.connect != nil ||
+		rn.methodHandlers.options != nil ||
+		rn.methodHandlers.trace != nil ||
+		len(rn.otherMethodHandlers) > 0
+}
 
-			http.Redirect(
-				rw,
-				req,
-				requestURI,
-				http.StatusMovedPermanently,
-			)
-		})
+// hasCatchAllHandler reports whether the rn has a non-nil catch-all handler,
+// including the one of a TSR (Trailing Slash Redirect) route.
+func (rn *routeNode) hasCatchAllHandler() bool {
+	return rn.catchAllHandler != nil && rn.catchAllHandler.handler != nil
+}
+
+// setPathParamNames sets the ppns as the path parameter names of the route of
+// the rn for the method. It must be called before the [routeNode.setHandler].
+//
+// The rn.pathParamNames are the ones of the first route of the rn, and the ones
+// of any other route are only stored in the rn.methodPathParamNames when they
+// differ.
+func (rn *routeNode) setPathParamNames(method string, ppns []string) {
+	if !rn.hasAtLeastOneHandler {
+		rn.pathParamNames = ppns
+		rn.methodPathParamNames = nil
+		return
 	}
 
-	if len(r.Middlewares) > 0 {
-		for i := len(r.Middlewares) - 1; i >= 0; i-- {
-			if r.Middlewares[i] != nil {
-				h = r.Middlewares[i].ChainHTTPHandler(h)
+	for i, ppn := range rn.pathParamNames {
+		if ppn != ppns[i] {
+			if rn.methodPathParamNames == nil {
+				rn.methodPathParamNames = map[string][]string{}
 			}
+
+			rn.methodPathParamNames[method] = ppns
+			return
 		}
 	}
 
-	r.chainedTSRHandler = h
-
-	return h
-}
-
-// routeNode is a node of a route radix tree.
-type routeNode struct {
-	prefix               string
-	label                byte
-	typ                  routeNodeType
-	parent               *routeNode
-	staticChildren       []*routeNode
-	paramChild           *routeNode
-	wildcardParamChild   *routeNode
-	hasAtLeastOneChild   bool
-	pathParamNames       []string
-	methodHandlers       *methodHandlers
-	otherMethodHandlers  []*methodHandler
-	catchAllHandler      *methodHandler
-	hasAtLeastOneHandler bool
+	delete(rn.methodPathParamNames, method)
 }
 
-// addChild adds the n as a child node to the rn.
-func (rn *routeNode) addChild(n *routeNode) {
-	switch n.typ {
-	case staticRouteNode:
-		rn.staticChildren[n.label] = n
-	case paramRouteNode:
-		rn.paramChild = n
-	case wildcardParamRouteNode:
-		rn.wildcardParamChild = n
+// pathParamNamesFor returns the path parameter names of the route of the rn for
+// the method.
+func (rn *routeNode) pathParamNamesFor(method string) []string {
+	if ppns, ok := rn.methodPathParamNames[method]; ok {
+		return ppns
 	}
 
-	rn.hasAtLeastOneChild = true
+	return rn.pathParamNames
 }
 
 // setHandler sets the h to the rn based on the method.
 func (rn *routeNode) setHandler(method string, h http.Handler) {
+	mhs := rn.methodHandlers
 	switch method {
 	case "", "_tsr":
-		if method == "_tsr" && rn.hasAtLeastOneHandler {
+		if h == nil || method == "_tsr" && rn.hasAtLeastOneHandler {
 			return
 		}
 
+		if method == "" && rn.catchAllHandler != nil &&
+			rn.catchAllHandler.method == "" {
+			h = mergeHandlers(rn.catchAllHandler.handler, h)
+		}
+
 		rn.catchAllHandler = &methodHandler{
 			method:  method,
 			handler: h,
 		}
 	case http.MethodGet:
-		rn.methodHandlers.get = h
+		mhs.get = mergeHandlers(mhs.get, h)
 	case http.MethodHead:
-		rn.methodHandlers.head = h
+		mhs.head = mergeHandlers(mhs.head, h)
 	case http.MethodPost:
-		rn.methodHandlers.post = h
+		mhs.post = mergeHandlers(mhs.post, h)
 	case http.MethodPut:
-		rn.methodHandlers.put = h
+		mhs.put = mergeHandlers(mhs.put, h)
 	case http.MethodPatch:
-		rn.methodHandlers.patch = h
+		mhs.patch = mergeHandlers(mhs.patch, h)
 	case http.MethodDelete:
-		rn.methodHandlers.delete = h
+		mhs.delete = mergeHandlers(mhs.delete, h)
 	case http.MethodConnect:
-		rn.methodHandlers.connect = h
+		mhs.connect = mergeHandlers(mhs.connect, h)
 	case http.MethodOptions:
-		rn.methodHandlers.options = h
+		mhs.options = mergeHandlers(mhs.options, h)
 	case http.MethodTrace:
-		rn.methodHandlers.trace = h
+		mhs.trace = mergeHandlers(mhs.trace, h)
 	default:
 		var exists bool
 		for i, mh := range rn.otherMethodHandlers {
 			if mh.method == method {
 				if h != nil {
+					h = mergeHandlers(mh.handler, h)
 					mh.handler = h
 				} else {
 					rn.otherMethodHandlers = append(
@@ -928,16 +3452,7 @@ func (rn *routeNode) setHandler(method string, h http.Handler) {
 		}
 	}
 
-	hasAtLeastOneMethodHandler := rn.methodHandlers.get != nil ||
-		rn.methodHandlers.head != nil ||
-		rn.methodHandlers.post != nil ||
-		rn.methodHandlers.put != nil ||
-		rn.methodHandlers.patch != nil ||
-		rn.methodHandlers.delete != nil ||
-		rn.methodHandlers.connect != nil ||
-		rn.methodHandlers.options != nil ||
-		rn.methodHandlers.trace != nil ||
-		len(rn.otherMethodHandlers) > 0
+	hasAtLeastOneMethodHandler := rn.hasMethodHandler()
 
 	if method != "_tsr" &&
 		hasAtLeastOneMethodHandler &&
@@ -947,7 +3462,7 @@ func (rn *routeNode) setHandler(method string, h http.Handler) {
 	}
 
 	rn.hasAtLeastOneHandler = hasAtLeastOneMethodHandler ||
-		rn.catchAllHandler != nil
+		rn.hasCatchAllHandler()
 }
 
 // routeNodeType is a type of a [routeNode].
@@ -960,6 +3475,293 @@ const (
 	wildcardParamRouteNode
 )
 
+// String returns the name of the typ.
+func (typ routeNodeType) String() string {
+	switch typ {
+	case paramRouteNode:
+		return "param"
+	case wildcardParamRouteNode:
+		return "wildcard"
+	}
+
+	return "static"
+}
+
+// pathParamConstraint is a constraint on the values of a path parameter.
+type pathParamConstraint struct {
+	key   string
+	match func(value string) bool
+}
+
+// isPathParamNameByte reports whether the c can appear in the name or the type
+// of a path parameter.
+func isPathParamNameByte(c byte) bool {
+	return (c >= '0' && c <= '9') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= 'a' && c <= 'z') ||
+		c == '_' ||
+		c >= 0x80
+}
+
+// newPathParamConstraint returns a new instance of the [pathParamConstraint]
+// with the pattern as a regular expression that must fully match the values.
+func newPathParamConstraint(
+	pattern string,
+) (*pathParamConstraint, *RouteError) {
+	if pattern == "" {
+		return nil, &RouteError{
+			Err: ErrInvalidRoutePath,
+			Detail: "route path parameter constraint cannot be " +
+				"empty",
+		}
+	}
+
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, &RouteError{
+			Err: ErrInvalidRoutePath,
+			Detail: "route path parameter constraint must be a " +
+				"valid regular expression",
+		}
+	}
+
+	return &pathParamConstraint{
+		key:   "<" + pattern + ">",
+		match: re.MatchString,
+	}, nil
+}
+
+// newTypedPathParamConstraint returns a new instance of the
+// [pathParamConstraint] that only matches values of the typ.
+func newTypedPathParamConstraint(
+	typ string,
+) (*pathParamConstraint, *RouteError) {
+	var match func(value string) bool
+	switch typ {
+	case "int":
+		match = func(value string) bool {
+			_, err := strconv.ParseInt(value, 10, 0)
+			return err == nil
+		}
+	case "uint":
+		match = func(value string) bool {
+			_, err := strconv.ParseUint(value, 10, 0)
+			return err == nil
+		}
+	case "bool":
+		match = func(value string) bool {
+			_, err := strconv.ParseBool(value)
+			return err == nil
+		}
+	case "uuid":
+		match = func(value string) bool {
+			_, err := parseUUID(value)
+			return err == nil
+		}
+	default:
+		return nil, &RouteError{
+			Err: ErrInvalidRoutePath,
+			Detail: "route path parameter type must be one of " +
+				"\"int\", \"uint\", \"bool\" and \"uuid\"",
+		}
+	}
+
+	return &pathParamConstraint{
+		key:   ":" + typ,
+		match: match,
+	}, nil
+}
+
+// mergeHandlers returns the result of registering the h after the old for the
+// same method and path. The result is a [matchingHandler] if either of them is.
+func mergeHandlers(old, h http.Handler) http.Handler {
+	if old == nil || h == nil {
+		return h
+	}
+
+	omh, ook := old.(*matchingHandler)
+	nmh, nok := h.(*matchingHandler)
+	if !ook && !nok {
+		return h
+	}
+
+	if !ook {
+		omh = &matchingHandler{
+			routes: []*matchingRoute{{handler: old}},
+		}
+	}
+
+	// The old one may still be in use (see the [Router.Concurrent]), so
+	// the routes are always copied.
+	routes := omh.routes[:len(omh.routes):len(omh.routes)]
+	if nok {
+		routes = append(routes, nmh.routes...)
+	} else {
+		routes = append(routes, &matchingRoute{handler: h})
+	}
+
+	return &matchingHandler{routes: routes}
+}
+
+// matchingHandler is an [http.Handler] that holds all routes registered for the
+// same method and path with [Matcher]s.
+type matchingHandler struct {
+	routes []*matchingRoute
+}
+
+// ServeHTTP implements the [http.Handler].
+func (mh *matchingHandler) ServeHTTP(
+	rw http.ResponseWriter,
+	req *http.Request,
+) {
+	if h, status := mh.match(req); h != nil {
+		h.ServeHTTP(rw, req)
+	} else {
+		http.Error(rw, http.StatusText(status), status)
+	}
+}
+
+// match returns the handler of the best route of the mh that matches the req,
+// which is the first one with the highest quality. The route without any
+// [Matcher]s is only used when no other route matches, regardless of the order
+// they are registered. It returns nil along with the status of the matching
+// failure if not found.
+func (mh *matchingHandler) match(req *http.Request) (http.Handler, int) {
+	var (
+		h      http.Handler
+		hq     float64
+		ph     http.Handler // Plain handler
+		status = http.StatusNotFound
+	)
+
+	for _, mr := range mh.routes {
+		if len(mr.matchers) == 0 {
+			ph = mr.handler
+			continue
+		}
+
+		q, s := mr.match(req)
+		if s != 0 {
+			if s > status {
+				status = s
+			}
+		} else if h == nil || q > hq {
+			h, hq = mr.handler, q
+		}
+	}
+
+	if h == nil {
+		h = ph
+	}
+
+	if h == nil {
+		return nil, status
+	}
+
+	return h, 0
+}
+
+// matchingRoute is a route registered with [Matcher]s.
+type matchingRoute struct {
+	matchers []Matcher
+	handler  http.Handler
+}
+
+// match returns the quality of the mr for the req, which is the quality of its
+// [Produces] or -1 if there is none, along with the status of the matching
+// failure. The status is 0 if the req is matched by all matchers of the mr.
+func (mr *matchingRoute) match(req *http.Request) (float64, int) {
+	q, status := -1.0, 0
+	for _, m := range mr.matchers {
+		switch m := m.(type) {
+		case *producesMatcher:
+			pq := m.quality(req)
+			if pq <= 0 && status == 0 {
+				status = http.StatusNotAcceptable
+			} else if q < 0 || pq < q {
+				q = pq
+			}
+		case *consumesMatcher:
+			if !m.Match(req) {
+				status = http.StatusUnsupportedMediaType
+			}
+		default:
+			if !m.Match(req) {
+				return 0, http.StatusNotFound
+			}
+		}
+	}
+
+	return q, status
+}
+
+// headResponseWriter is an [http.ResponseWriter] for serving HEAD requests with
+// GET handlers. It discards the response body and delays writing the response
+// header until the writeHeader is called.
+type headResponseWriter struct {
+	http.ResponseWriter
+
+	status        int
+	contentLength int64
+	wroteHeader   bool
+}
+
+// WriteHeader implements the [http.ResponseWriter].
+func (hrw *headResponseWriter) WriteHeader(status int) {
+	if hrw.status == 0 {
+		hrw.status = status
+	}
+}
+
+// Write implements the [http.ResponseWriter].
+func (hrw *headResponseWriter) Write(b []byte) (int, error) {
+	hrw.WriteHeader(http.StatusOK)
+	hrw.contentLength += int64(len(b))
+	return len(b), nil
+}
+
+// Flush implements the [http.Flusher]. It writes the response header without
+// setting the Content-Length header, since the length of the response body is
+// unknown yet, and then flushes the underlying [http.ResponseWriter] if it is
+// an [http.Flusher].
+func (hrw *headResponseWriter) Flush() {
+	hrw.WriteHeader(http.StatusOK)
+	if !hrw.wroteHeader {
+		hrw.wroteHeader = true
+		hrw.ResponseWriter.WriteHeader(hrw.status)
+	}
+
+	if f, ok := hrw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeHeader writes the response header with the Content-Length header set to
+// the length of the discarded response body if it is not set. It does nothing
+// if the response header has already been written by the
+// [headResponseWriter.Flush].
+func (hrw *headResponseWriter) writeHeader() {
+	if hrw.wroteHeader {
+		return
+	}
+
+	hrw.WriteHeader(http.StatusOK)
+
+	h := hrw.Header()
+	if h.Get("Content-Length") == "" &&
+		h.Get("Transfer-Encoding") == "" &&
+		hrw.status >= http.StatusOK &&
+		hrw.status != http.StatusNoContent &&
+		hrw.status != http.StatusNotModified {
+		h.Set(
+			"Content-Length",
+			strconv.FormatInt(hrw.contentLength, 10),
+		)
+	}
+
+	hrw.ResponseWriter.WriteHeader(hrw.status)
+}
+
 // methodHandler is an [http.Handler] for an HTTP method.
 type methodHandler struct {
 	method  string
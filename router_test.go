@@ -1,10 +1,16 @@
 package r2
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -32,11 +38,11 @@ func TestRouterSub(t *testing.T) {
 func TestRouterHandle(t *testing.T) {
 	r := &Router{}
 	r.Handle("", "/", http.NotFoundHandler())
-	if r.routeTree == nil {
+	if tb := r.loadTable(); tb.routeTree == nil {
 		t.Fatal("unexpected nil")
-	} else if r.routeTree.methodHandlers == nil {
+	} else if tb.routeTree.methodHandlers == nil {
 		t.Fatal("unexpected nil")
-	} else if r.registeredRoutes == nil {
+	} else if tb.registeredRoutes == nil {
 		t.Fatal("unexpected nil")
 	}
 
@@ -46,15 +52,15 @@ func TestRouterHandle(t *testing.T) {
 		PathPrefix: "/sub",
 	}
 	sr.Handle("", "/", http.NotFoundHandler(), nil)
-	if sr.routeTree != nil {
-		t.Errorf("got %v, want nil", sr.routeTree)
-	} else if sr.registeredRoutes != nil {
-		t.Errorf("got %v, want nil", sr.registeredRoutes)
-	} else if r.routeTree == nil {
+	if stb := sr.loadTable(); stb.routeTree != nil {
+		t.Errorf("got %v, want nil", stb.routeTree)
+	} else if stb.registeredRoutes != nil {
+		t.Errorf("got %v, want nil", stb.registeredRoutes)
+	} else if tb := r.loadTable(); tb.routeTree == nil {
 		t.Fatal("unexpected nil")
-	} else if r.routeTree.methodHandlers == nil {
+	} else if tb.routeTree.methodHandlers == nil {
 		t.Fatal("unexpected nil")
-	} else if r.registeredRoutes == nil {
+	} else if tb.registeredRoutes == nil {
 		t.Fatal("unexpected nil")
 	}
 
@@ -121,7 +127,7 @@ func TestRouterHandle(t *testing.T) {
 		}()
 
 		r = &Router{}
-		r.Handle("", "/foo/*/bar", http.NotFoundHandler())
+		r.Handle("", "/foo/*.bar", http.NotFoundHandler())
 	}()
 
 	func() {
@@ -179,89 +185,3080 @@ func TestRouterHandle(t *testing.T) {
 		r = &Router{}
 		r.Handle("", "/", nil)
 	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar<[0-9]+", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar<>", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar<[0-9+>", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar<[0-9]+>", http.NotFoundHandler())
+		r.Handle("", "/foo/:baz<[0-9]+>", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar:int<[0-9]+>", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar:int:baz", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar<[a-z]+>:baz", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/:bar:int", http.NotFoundHandler())
+		r.Handle("", "/foo/:baz:int", http.NotFoundHandler())
+	}()
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/*bar.baz", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/:foo/*foo", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/*foo/:foo", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/*bar", http.NotFoundHandler())
+		r.Handle("", "/foo/*baz", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/bar?", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/bar>?", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo:bar?", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo(/bar", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo/bar)", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo()/bar", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{}
+		r.Handle("", "/foo", http.NotFoundHandler())
+		r.Handle("", "/foo/:bar?", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{Host: "foo..com"}
+		r.Handle("", "/foo", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{Host: "{}.foo.com"}
+		r.Handle("", "/foo", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{Host: "{foo-bar}.com"}
+		r.Handle("", "/foo", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{Host: "{foo}.{foo}.com"}
+		r.Handle("", "/foo", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{Host: "foo{bar}.com"}
+		r.Handle("", "/foo", http.NotFoundHandler())
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r = &Router{Host: "foo.com"}
+		r.Handle("", "/foo", http.NotFoundHandler())
+		r.Handle("", "/foo", http.NotFoundHandler())
+	}()
+}
+
+func TestRouterTryHandle(t *testing.T) {
+	r := &Router{}
+	if err := r.TryHandle(
+		http.MethodGet,
+		"/foo",
+		http.NotFoundHandler(),
+		RouteName("foo"),
+	); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+
+	sr := r.Sub("/sub")
+	if err := sr.TryHandle(
+		http.MethodGet,
+		"/bar",
+		http.NotFoundHandler(),
+	); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := len(r.Routes()), 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	for _, tt := range []struct {
+		host       string
+		method     string
+		path       string
+		h          http.Handler
+		ms         []Middleware
+		wantErr    error
+		wantDetail string
+	}{
+		{
+			method:     "_",
+			path:       "/",
+			wantErr:    ErrInvalidRouteMethod,
+			wantDetail: "route method must be alphanumeric",
+		},
+		{
+			path:       "",
+			wantErr:    ErrInvalidRoutePath,
+			wantDetail: "route path cannot be empty",
+		},
+		{
+			path:       "foo",
+			wantErr:    ErrInvalidRoutePath,
+			wantDetail: "route path must start with '/'",
+		},
+		{
+			host:       "{}.example.com",
+			path:       "/",
+			wantErr:    ErrInvalidRouteHost,
+			wantDetail: "route host parameter name cannot be empty",
+		},
+		{
+			path:       "/bar",
+			ms:         []Middleware{RouteName("foo")},
+			wantErr:    ErrRouteNameAlreadyExists,
+			wantDetail: "route name already exists",
+		},
+		{
+			path:    "/:foo<[0-9]+",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "route path parameter constraint must " +
+				"end with '>'",
+		},
+		{
+			path:    "/foo?",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "'?' can only follow a route path " +
+				"parameter that starts a path element",
+		},
+		{
+			path:    "/foo(/bar",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "route path optional segment must end " +
+				"with ')'",
+		},
+		{
+			path:    "/foo(/bar())",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "route path optional segment cannot be " +
+				"empty",
+		},
+		{
+			path:    "/foo(/bar)(/baz",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "route path optional segment must end " +
+				"with ')'",
+		},
+		{
+			path:    "/users/:user-id",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "route path parameter followed by " +
+				"static characters must have a type or a " +
+				"constraint",
+		},
+		{
+			path:       "/*/*",
+			wantErr:    ErrConflictingWildcardParam,
+			wantDetail: "only one '*' is allowed in a route path",
+		},
+		{
+			path:    "/*foo.bar",
+			wantErr: ErrConflictingWildcardParam,
+			wantDetail: "'*' can only appear at the end of a " +
+				"route path or before a '/'",
+		},
+		{
+			path:    "/:foo*",
+			wantErr: ErrConflictingWildcardParam,
+			wantDetail: "':' and '*' cannot appear in the same " +
+				"route path element",
+		},
+		{
+			path:    "/:foo:bar",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "route path parameter type must be one " +
+				"of \"int\", \"uint\", \"bool\" and \"uuid\"",
+		},
+		{
+			path:    "/:foo<(>",
+			wantErr: ErrInvalidRoutePath,
+			wantDetail: "route path parameter constraint must " +
+				"be a valid regular expression",
+		},
+		{
+			path:       "/foo",
+			wantErr:    ErrRouteAlreadyExists,
+			wantDetail: "route already exists",
+		},
+		{
+			path:       "/baz(/qux)(/qux)",
+			wantErr:    ErrRouteAlreadyExists,
+			wantDetail: "route already exists",
+		},
+		{
+			path:       "/baz",
+			wantErr:    ErrNilRouteHandler,
+			wantDetail: "route handler cannot be nil",
+		},
+	} {
+		method, h := tt.method, tt.h
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		if h == nil && tt.wantErr != ErrNilRouteHandler {
+			h = http.NotFoundHandler()
+		}
+
+		hr := &Router{Parent: r, Host: tt.host}
+		err := hr.TryHandle(method, tt.path, h, tt.ms...)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+
+		var re *RouteError
+		if !errors.As(err, &re) {
+			t.Fatalf("got %T, want %T", err, re)
+		} else if got, want := re.Method, method; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		} else if got, want := re.Path, tt.path; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		} else if !errors.Is(err, tt.wantErr) {
+			t.Errorf("got %q, want %q", re.Err, tt.wantErr)
+		} else if got, want := re.Detail, tt.wantDetail; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	if got, want := len(r.Routes()), 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := len(
+		r.loadTable().hostRouteTrees,
+	), 0; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/baz",
+	).Status, MatchNotFound; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	r = &Router{}
+	if err := r.TryHandle(http.MethodGet, "", nil); err == nil {
+		t.Fatal("expected error")
+	} else if rt := r.loadTable().routeTree; rt != nil {
+		t.Errorf("got %v, want nil", rt)
+	}
+}
+
+func TestRouterRemove(t *testing.T) {
+	r := &Router{}
+	if r.Remove(http.MethodGet, "/") {
+		t.Error("want false")
+	}
+
+	r.Handle(http.MethodGet, "/", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/:id:int", http.NotFoundHandler())
+	r.Handle(http.MethodPost, "/users/:id:int", http.NotFoundHandler())
+	r.Handle("FOO", "/users/:id:int", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/:name/posts", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/files/*filepath", http.NotFoundHandler())
+	r.Handle(http.MethodPost, "/files/*filepath", http.NotFoundHandler())
+	r.Handle(
+		http.MethodGet,
+		"/posts(/:page)",
+		http.NotFoundHandler(),
+		RouteName("posts"),
+	)
+	r.Handle("", "/foo", http.NotFoundHandler())
+
+	hr := &Router{Parent: r, Host: "example.com"}
+	hr.Handle(http.MethodGet, "/foo", http.NotFoundHandler())
+
+	sr := r.Sub("/sub")
+	sr.Handle(http.MethodGet, "/foo", http.NotFoundHandler())
+
+	for _, tt := range []struct {
+		host   string
+		method string
+		path   string
+	}{
+		{method: "_tsr", path: "/files"},
+		{method: http.MethodGet, path: ""},
+		{method: http.MethodGet, path: "foo"},
+		{method: http.MethodGet, path: "/:foo<[0-9]+"},
+		{method: http.MethodGet, path: "/bar"},
+		{method: http.MethodGet, path: "/users"},
+		{method: http.MethodDelete, path: "/users/:id:int"},
+		{method: http.MethodPut, path: "/users/:id:int"},
+		{method: "BAR", path: "/users/:id:int"},
+		{method: http.MethodGet, path: "/users/:id"},
+		{method: http.MethodGet, path: "/foo"},
+		{path: "/"},
+		{host: "example.net", method: http.MethodGet, path: "/foo"},
+	} {
+		if (&Router{Parent: r, Host: tt.host}).Remove(
+			tt.method,
+			tt.path,
+		) {
+			t.Errorf(
+				"%s %s%s: want false",
+				tt.method,
+				tt.host,
+				tt.path,
+			)
+		}
+	}
+
+	if got, want := len(r.Routes()), 12; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	if !r.Remove(http.MethodGet, "/users/:id:int") {
+		t.Error("want true")
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/users/1",
+	).Status, MatchMethodNotAllowed; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := r.Match(
+		http.MethodPost,
+		"/users/1",
+	).Status, MatchFound; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if !r.Remove(http.MethodPost, "/users/:id:int") {
+		t.Error("want true")
+	} else if !r.Remove("FOO", "/users/:id:int") {
+		t.Error("want true")
+	} else if got, want := r.Match(
+		"FOO",
+		"/users/1",
+	).Status, MatchNotFound; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if !r.Remove(http.MethodGet, "/files/*filepath") {
+		t.Error("want true")
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/files",
+	).Status, MatchTSR; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if !r.Remove(http.MethodPost, "/files/*") {
+		t.Error("want true")
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/files",
+	).Status, MatchNotFound; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/files", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/files/*", http.NotFoundHandler())
+	if !r.Remove(http.MethodGet, "/files") {
+		t.Error("want true")
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/files",
+	).Status, MatchTSR; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodPost, "/files", http.NotFoundHandler())
+	if !r.Remove(http.MethodGet, "/files/*") {
+		t.Error("want true")
+	} else if got, want := r.Match(
+		http.MethodPost,
+		"/files",
+	).Status, MatchFound; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if !r.Remove(http.MethodPost, "/files") {
+		t.Error("want true")
+	}
+
+	if !r.Remove(http.MethodGet, "/posts") {
+		t.Error("want true")
+	} else if got, err := r.URL("posts", "page", "2"); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "/posts/2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if _, err := r.URL("posts"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !r.Remove(http.MethodGet, "/posts/:page") {
+		t.Error("want true")
+	} else if _, err := r.URL("posts", "page", "2"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	r.Handle(http.MethodGet, "/posts", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/posts/:page", http.NotFoundHandler())
+	r.Handle(http.MethodPost, "/posts(/:page)", http.NotFoundHandler())
+	if !r.Remove(http.MethodPost, "/posts(/:page)") {
+		t.Error("want true")
+	} else if !r.Remove(http.MethodGet, "/posts/:page") {
+		t.Error("want true")
+	} else if !r.Remove(http.MethodGet, "/posts") {
+		t.Error("want true")
+	}
+
+	if !r.Remove("", "/foo") {
+		t.Error("want true")
+	} else if !hr.Remove(http.MethodGet, "/foo") {
+		t.Error("want true")
+	} else if !sr.Remove(http.MethodGet, "/foo") {
+		t.Error("want true")
+	}
+
+	routes := r.Routes()
+	if got, want := len(routes), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	} else if got, want := routes[0].Pattern, "/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := routes[1].Pattern,
+		"/users/:name/posts"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var b bytes.Buffer
+	if err := r.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), `host: *
+/ (static) methods: GET
+└── users/ (static)
+    └── : (param)
+        └── /posts (static) methods: GET params: name
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/files/*filepath", http.NotFoundHandler())
+	if got, want := len(r.Routes()), 4; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/files",
+	).Status, MatchTSR; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r = &Router{}
+	r.Handle(http.MethodGet, "/a/*", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/a", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/b/:id", http.NotFoundHandler())
+	r.Handle(http.MethodPost, "/b/:id", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/posts/:page?", http.NotFoundHandler())
+	for _, rt := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/a/*"},
+		{http.MethodGet, "/a"},
+		{http.MethodGet, "/b/:id"},
+		{http.MethodPost, "/b/:id"},
+		{http.MethodGet, "/posts/:page?"},
+	} {
+		if !r.Remove(rt.method, rt.path) {
+			t.Errorf("%s %s: want true", rt.method, rt.path)
+		}
+	}
+
+	b.Reset()
+	if err := r.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/a/*", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/b/:x", http.NotFoundHandler())
+
+	b.Reset()
+	if err := r.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), `/ (static)
+├── a (static) methods: TSR
+│   └── / (static)
+│       └── * (wildcard) methods: GET params: *
+└── b/ (static)
+    └── : (param) methods: GET params: x
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r = &Router{}
+	r.Handle("", "/a/:b/c", http.NotFoundHandler())
+	if !r.Remove("", "/a/:b/c") {
+		t.Error("want true")
+	} else if got, want := len(r.Routes()), 0; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/a/x",
+	).Status, MatchNotFound; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	b.Reset()
+	if err := r.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterConcurrent(t *testing.T) {
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(PathParam(req, "bar")))
+	})
+
+	r := &Router{Concurrent: true}
+	hr := &Router{Parent: r, Host: "api.example.com"}
+	r.Handle(http.MethodGet, "/foo/:bar", h)
+	r.Handle("FOO", "/foo/:bar", h)
+	r.Handle("", "/baz", h)
+	r.Handle(http.MethodGet, "/files/*", h, RouteName("files"))
+	r.Handle(http.MethodGet, "/qux", h, MatchHeader("X-Foo", "1"))
+	hr.Handle(http.MethodGet, "/foo", h)
+
+	tb := r.loadTable()
+	mh := tb.routeTree.node("/qux", nil).methodHandlers.get
+
+	r.Handle(http.MethodGet, "/qux", h, MatchHeader("X-Foo", "2"))
+	hr.Handle(http.MethodGet, "/bar", h)
+	if r.loadTable() == tb {
+		t.Error("want a new route table")
+	} else if got, want := len(
+		mh.(*matchingHandler).routes,
+	), 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := len(tb.routes), 7; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if n := tb.hostRouteTrees[0].routeTree.node(
+		"/bar",
+		nil,
+	); n != nil {
+		t.Errorf("got %v, want nil", n)
+	}
+
+	tb = r.loadTable()
+	if !r.Remove(http.MethodGet, "/foo/:bar") {
+		t.Error("want true")
+	} else if n := tb.routeTree.node(
+		"/foo/:",
+		[]*pathParamConstraint{nil},
+	); n == nil {
+		t.Fatal("unexpected nil")
+	} else if !n.hasHandler(http.MethodGet) {
+		t.Error("want true")
+	} else if r.Remove(http.MethodGet, "/foo/:bar") {
+		t.Error("want false")
+	}
+
+	r.Handle(http.MethodGet, "/foo/:bar", h)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				req := httptest.NewRequest(
+					http.MethodGet,
+					"/foo/bar",
+					nil,
+				)
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+				got, want := rec.Body.String(), "bar"
+				if got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		path := fmt.Sprintf("/dyn/%d", i)
+		for j := 0; j < i%5; j++ {
+			path += fmt.Sprintf("/:p%d", j)
+		}
+
+		r.Handle(http.MethodGet, path, h)
+		if i%2 == 0 {
+			r.Remove(http.MethodGet, path)
+		}
+	}
+
+	wg.Wait()
+
+	if got, want := len(r.Routes()), 59; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	var served sync.WaitGroup
+	r = &Router{Concurrent: true}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		served.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if j == 1 {
+					served.Done()
+				}
+
+				req := httptest.NewRequest(
+					http.MethodGet,
+					"/foo/bar",
+					nil,
+				)
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK &&
+					rec.Code != http.StatusNotFound {
+					t.Errorf("unexpected %d", rec.Code)
+				}
+			}
+		}()
+	}
+
+	served.Wait()
+	r.Handle(http.MethodGet, "/foo/:bar", h)
+
+	wg.Wait()
+}
+
+func TestRouterURL(t *testing.T) {
+	r := &Router{}
+	if _, err := r.URL("user"); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("got %q, want %q", err, ErrRouteNotFound)
+	}
+
+	r.Handle(
+		http.MethodGet,
+		"/users/:id:int",
+		http.NotFoundHandler(),
+		RouteName("user"),
+	)
+	r.Handle(
+		http.MethodGet,
+		"/archive(/:year(/:month))",
+		http.NotFoundHandler(),
+		RouteName("archive"),
+	)
+	r.Handle(
+		http.MethodGet,
+		"/files/:name.:ext",
+		http.NotFoundHandler(),
+		RouteName("file"),
+	)
+	r.Handle(
+		http.MethodGet,
+		"/repos/*repo/blob/:ref",
+		http.NotFoundHandler(),
+		RouteName("blob"),
+	)
+	r.Handle(
+		http.MethodGet,
+		"/static/*",
+		http.NotFoundHandler(),
+		RouteName("static"),
+	)
+	r.Sub("/sub sites").Handle(
+		http.MethodGet,
+		"/:name",
+		http.NotFoundHandler(),
+		RouteName("site"),
+	)
+
+	sr := r.Sub("/api")
+	for _, tc := range []struct {
+		name   string
+		params []string
+		url    string
+	}{
+		{"user", []string{"id", "1"}, "/users/1"},
+		{"archive", nil, "/archive"},
+		{"archive", []string{"year", "2023"}, "/archive/2023"},
+		{
+			"archive",
+			[]string{"month", "01", "year", "2023"},
+			"/archive/2023/01",
+		},
+		{
+			"file",
+			[]string{"name", "a b", "ext", "txt"},
+			"/files/a%20b.txt",
+		},
+		{
+			"blob",
+			[]string{"repo", "a/b c", "ref", "x/y"},
+			"/repos/a/b%20c/blob/x%2Fy",
+		},
+		{
+			"blob",
+			[]string{"*", "a/b", "ref", "main"},
+			"/repos/a/b/blob/main",
+		},
+		{"static", []string{"*", ""}, "/static/"},
+		{"static", []string{"*", "js/app.js"}, "/static/js/app.js"},
+		{"site", []string{"name", "foo"}, "/sub%20sites/foo"},
+	} {
+		if got, err := sr.URL(tc.name, tc.params...); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		} else if got != tc.url {
+			t.Errorf("got %q, want %q", got, tc.url)
+		}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		params    []string
+		pathParam string
+		err       error
+	}{
+		{"foo", nil, "", ErrRouteNotFound},
+		{"user", nil, "id", ErrMissingPathParam},
+		{"user", []string{"id"}, "id", ErrInvalidPathParam},
+		{"user", []string{"id", "foo"}, "id", ErrInvalidPathParam},
+		{
+			"user",
+			[]string{"id", "1", "foo", "bar"},
+			"foo",
+			ErrUnknownPathParam,
+		},
+		{
+			"archive",
+			[]string{"month", "01"},
+			"year",
+			ErrMissingPathParam,
+		},
+		{"file", []string{"name", "a"}, "ext", ErrMissingPathParam},
+		{
+			"file",
+			[]string{"name", "", "ext", "txt"},
+			"name",
+			ErrInvalidPathParam,
+		},
+		{
+			"blob",
+			[]string{"repo", "", "ref", "main"},
+			"*repo",
+			ErrInvalidPathParam,
+		},
+	} {
+		_, err := r.URL(tc.name, tc.params...)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+
+		ue, ok := err.(*URLError)
+		if !ok {
+			t.Fatalf("got %T, want %T", err, ue)
+		} else if got, want := ue.Route, tc.name; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		} else if got, want := ue.PathParam, tc.pathParam; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		} else if got, want := ue.Err, tc.err; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		r.Handle(
+			http.MethodPost,
+			"/users",
+			http.NotFoundHandler(),
+			RouteName("user"),
+		)
+	}()
+}
+
+func TestRouterURLWithQuery(t *testing.T) {
+	r := &Router{}
+	r.Handle(
+		http.MethodGet,
+		"/users/:id",
+		http.NotFoundHandler(),
+		RouteName("user"),
+	)
+
+	if got, err := r.URLWithQuery(
+		"user",
+		url.Values{"tab": {"posts"}, "q": {"a&b"}},
+		"id", "1",
+	); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "/users/1?q=a%26b&tab=posts"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, err := r.URLWithQuery("user", nil, "id", "1"); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "/users/1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := r.URLWithQuery("user", nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	r := &Router{}
+	if got := r.Routes(); len(got) != 0 {
+		t.Errorf("got %d, want %d", len(got), 0)
+	}
+
+	mf := MiddlewareFunc(func(next http.Handler) http.Handler {
+		return next
+	})
+
+	r.Middlewares = []Middleware{mf}
+	r.Handle(http.MethodGet, "/", http.NotFoundHandler())
+	r.Handle(
+		http.MethodGet,
+		"/posts(/:page:int)",
+		http.NotFoundHandler(),
+		RouteName("posts"),
+		MatchQuery("foo", "bar"),
+		nil,
+	)
+
+	sr := r.Sub("/foo", mf)
+	sr.Handle("", "/bar/*filepath", http.NotFoundHandler(), mf)
+	sr.Handle(http.MethodGet, "/baz", http.NotFoundHandler())
+	sr.Handle(http.MethodGet, "/baz/*", http.NotFoundHandler())
+	sr.Handle(http.MethodGet, "/qux/*", http.NotFoundHandler())
+	sr.Handle(http.MethodPost, "/qux", http.NotFoundHandler())
+
+	hr := &Router{Parent: r, Host: "Example.com"}
+	hr.Handle(http.MethodGet, "/users/*", http.NotFoundHandler())
+
+	want := []Route{
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/",
+			Middlewares: 1,
+		},
+		{
+			Method:         http.MethodGet,
+			Pattern:        "/posts(/:page:int)",
+			Name:           "posts",
+			PathParamNames: []string{"page"},
+			Middlewares:    1,
+		},
+		{
+			Pattern:        "/foo/bar/*filepath",
+			PathParamNames: []string{"*filepath"},
+			Middlewares:    3,
+		},
+		{
+			Pattern: "/foo/bar",
+			TSR:     true,
+		},
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/foo/baz",
+			Middlewares: 2,
+		},
+		{
+			Method:         http.MethodGet,
+			Pattern:        "/foo/baz/*",
+			PathParamNames: []string{"*"},
+			Middlewares:    2,
+		},
+		{
+			Method:         http.MethodGet,
+			Pattern:        "/foo/qux/*",
+			PathParamNames: []string{"*"},
+			Middlewares:    2,
+		},
+		{
+			Method:      http.MethodPost,
+			Pattern:     "/foo/qux",
+			Middlewares: 2,
+		},
+		{
+			Host:           "example.com",
+			Method:         http.MethodGet,
+			Pattern:        "/users/*",
+			PathParamNames: []string{"*"},
+			Middlewares:    1,
+		},
+		{
+			Host:    "example.com",
+			Pattern: "/users",
+			TSR:     true,
+		},
+	}
+
+	for _, got := range [][]Route{r.Routes(), sr.Routes()} {
+		if len(got) != len(want) {
+			t.Fatalf("got %d, want %d", len(got), len(want))
+		}
+
+		for i := range want {
+			if got, want := fmt.Sprint(got[i]),
+				fmt.Sprint(want[i]); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		}
+	}
+}
+
+func TestRouterHandler(t *testing.T) {
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, req.Host == "www.example.com")
+	})
+	mf := MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			req.Host = "www.example.com"
+			next.ServeHTTP(rw, req)
+		})
+	})
+
+	r := &Router{}
+	r.Handle("", "/", h, mf)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "true"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r = &Router{}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r = &Router{}
+	sr := &Router{
+		Parent:     r,
+		PathPrefix: "/foo",
+	}
+	sr.Handle("", "/", h, mf)
+	req = httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = sr.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "true"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r = &Router{}
+	r.Handle("", "/", h, mf)
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.URL.Path = ""
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r = &Router{}
+	r.Handle("", "/", h, mf)
+	req = httptest.NewRequest(http.MethodGet, "/?foo=bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "true"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := req.URL.Query().Get("foo"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_static(t *testing.T) {
+	r := &Router{}
+	r.Handle(http.MethodGet, "/", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /")
+	}))
+	r.Handle("custom", "/foo", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "custom /foo")
+	}))
+	r.Handle("", "/foobar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "_ /foobar")
+	}))
+	r.Handle(http.MethodGet, "/foo/bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo/bar")
+	}))
+	r.Handle(http.MethodGet, "/foo/bar/", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo/bar/")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "//", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest("custom", "/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "custom /foo"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest("bar", "/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Method Not Allowed\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	for _, method := range []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodConnect,
+		http.MethodOptions,
+		http.MethodTrace,
+	} {
+		req = httptest.NewRequest(method, "/foobar", nil)
+		rec = httptest.NewRecorder()
+		mh, req = r.Handler(req)
+		mh.ServeHTTP(rec, req)
+		recr = rec.Result()
+		if want := http.StatusOK; recr.StatusCode != want {
+			t.Errorf("got %d, want %d", recr.StatusCode, want)
+		} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		} else if want := "_ /foobar"; string(b) != want {
+			t.Errorf("got %q, want %q", b, want)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo/bar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo/bar/"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/bar/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestRouterHandler_param(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/:foobar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /:foobar")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /:foobar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foobar"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "//", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Method Not Allowed\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /:foobar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foobar"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r.Handle(http.MethodGet, "/foo:bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo:bar")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo:bar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "bar"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo:bar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/:foo/:bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /:foo/:bar")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	req = req.WithContext(&dataContext{d: &data{}})
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /:foo/:bar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foo"), "foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_paramConstraint(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/users/:name", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /users/:name")
+	}))
+	r.Handle(http.MethodGet, "/users/:id<[0-9]+>", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /users/:id<[0-9]+>")
+	}))
+	r.Handle(http.MethodGet, "/users/:slug<[a-z-]+>", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /users/:slug<[a-z-]+>")
+	}))
+	r.Handle(
+		http.MethodGet,
+		"/users/:id<[0-9]+>/posts",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "GET /users/:id<[0-9]+>/posts")
+		}),
+	)
+	r.Handle(http.MethodGet, "/users/:name/profile", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /users/:name/profile")
+	}))
+	r.Handle(
+		http.MethodGet,
+		"/files/:name<[^/.]+(?P<ext>\\.[a-z]{2,4})?>",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "GET /files/:name")
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users/:id<[0-9]+>"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "id"), "123"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/foo-bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users/:slug<[a-z-]+>"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "slug"), "foo-bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/Foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users/:name"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "name"), "Foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/123/posts", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users/:id<[0-9]+>/posts"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "id"), "123"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/123/profile", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users/:name/profile"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "name"), "123"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/foo/posts", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/files/foo.txt", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /files/:name"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "name"), "foo.txt"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/files/foo.bar.txt", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestRouterHandler_typedParam(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/users/:id:int", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		id, err := PathParamInt(req, "id")
+		if err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		fmt.Fprintf(rw, "GET /users/:id:int %d", id)
+	}))
+	r.Handle(http.MethodGet, "/users/:uuid:uuid", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		if _, err := PathParamUUID(req, "uuid"); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		fmt.Fprint(rw, "GET /users/:uuid:uuid")
+	}))
+	r.Handle(http.MethodGet, "/pages/:page:uint", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		page, err := PathParamUint(req, "page")
+		if err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		fmt.Fprintf(rw, "GET /pages/:page:uint %d", page)
+	}))
+	r.Handle(http.MethodGet, "/flags/:flag:bool", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		flag, err := PathParamBool(req, "flag")
+		if err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		fmt.Fprintf(rw, "GET /flags/:flag:bool %t", flag)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/-123", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users/:id:int -123"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(
+		http.MethodGet,
+		"/users/123e4567-e89b-12d3-a456-426614174000",
+		nil,
+	)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users/:uuid:uuid"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/foobar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/pages/2", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /pages/:page:uint 2"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/pages/-2", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/flags/false", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /flags/:flag:bool false"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/flags/maybe", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestRouterHandler_multiParam(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/files/:name", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /files/:name")
+	}))
+	r.Handle(http.MethodGet, "/files/:name.:ext", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /files/:name.:ext")
+	}))
+	r.Handle(http.MethodGet, "/reports/:year-:month", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /reports/:year-:month")
+	}))
+	r.Handle(http.MethodGet, "/avatars/:id<.+>.png", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /avatars/:id<.+>.png")
+	}))
+	r.Handle(http.MethodGet, "/avatars/:id:int.jpg", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /avatars/:id:int.jpg")
+	}))
+	r.Handle(http.MethodGet, "/avatars/:id/info", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /avatars/:id/info")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/readme", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /files/:name"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "name"), "readme"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/files/archive.tar.gz", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /files/:name.:ext"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "name"), "archive"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "ext"), "tar.gz"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/reports/2006-01", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /reports/:year-:month"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "year"), "2006"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "month"), "01"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/reports/2006", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/avatars/foo.bar.png", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /avatars/:id<.+>.png"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "id"), "foo.bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/avatars/123.jpg", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /avatars/:id:int.jpg"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "id"), "123"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/avatars/foo.jpg", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/avatars/foo.png/info", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /avatars/:id/info"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "id"), "foo.png"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_wildcardParam(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /*")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "//", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Method Not Allowed\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foobar/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar//", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foobar//"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foo/bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foo/bar/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar//", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foo/bar//"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/foobar*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foobar*")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foobar*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foobar*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar//", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foobar*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "//"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/foobar/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foobar/*")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foobar/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/foobar2/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foobar2/*")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar2/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foobar2/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/foo/bar/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo/bar/*")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo/bar/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMovedPermanently; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if loc, err := recr.Location(); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := loc.String(), "/foo/bar/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar?foo=bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMovedPermanently; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if loc, err := recr.Location(); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := loc.String(), "/foo/bar/?foo=bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/foo/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMovedPermanently; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if loc, err := recr.Location(); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := loc.String(), "/foo/bar/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/foo/bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "_ /foo/bar")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "_ /foo/bar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/foo/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Method Not Allowed\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r.Handle("custom", "/barfoo/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "custom /barfoo/*")
+	}))
+
+	req = httptest.NewRequest("custom", "/barfoo/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "custom /barfoo/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle("", "/barfoo/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "_ /barfoo/*")
+	}))
+
+	for _, method := range []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodConnect,
+		http.MethodOptions,
+		http.MethodTrace,
+	} {
+		req = httptest.NewRequest(method, "/barfoo/", nil)
+		rec = httptest.NewRecorder()
+		mh, req = r.Handler(req)
+		mh.ServeHTTP(rec, req)
+		recr = rec.Result()
+		if want := http.StatusOK; recr.StatusCode != want {
+			t.Errorf("got %d, want %d", recr.StatusCode, want)
+		} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		} else if want := "_ /barfoo/*"; string(b) != want {
+			t.Errorf("got %q, want %q", b, want)
+		} else if got, want := PathParam(req, "*"), ""; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	r.Handle(http.MethodGet, "/bar/foo*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /bar/foo*")
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/bar/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /bar/foo*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/bar/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "bar/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_namedWildcardParam(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/static/*filepath", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /static/*filepath")
+	}))
+	r.Handle(
+		http.MethodGet,
+		"/repos/*repo/blob/:ref",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "GET /repos/*repo/blob/:ref")
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/static/js", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /static/*filepath"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "filepath"), "js"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "*"), "js"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "*filepath"), "js"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if ppns := PathParamNames(req); len(ppns) != 1 {
+		t.Errorf("got %d, want %d", len(ppns), 1)
+	} else if got, want := ppns[0], "*filepath"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/static", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMovedPermanently; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if loc, err := recr.Location(); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := loc.String(), "/static/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/repos/a/b/blob/main", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /repos/*repo/blob/:ref"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "repo"), "a/b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "*"), "a/b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "ref"), "main"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_midWildcardParam(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/repos/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /repos/*")
+	}))
+	r.Handle(http.MethodGet, "/repos/*/blob/:ref", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /repos/*/blob/:ref")
+	}))
+	r.Handle(http.MethodGet, "/group/*/-/issues", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /group/*/-/issues")
+	}))
+	r.Handle(http.MethodPost, "/:foo/-/issues", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "POST /:foo/-/issues")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/repos/a/b/blob/main", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /repos/*/blob/:ref"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "a/b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "ref"), "main"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/repos/a/blob/b/blob/c", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /repos/*/blob/:ref"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "a/blob/b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "ref"), "c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/repos/a/blob/b/", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /repos/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "a/blob/b/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/repos/a/b", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /repos/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "a/b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/repos//blob/main", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /repos/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "/blob/main"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/group/a/b/-/issues", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /group/*/-/issues"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "a/b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/group/-/issues", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Method Not Allowed\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/group/foo/-/pulls", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/group/-/issues", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "POST /:foo/-/issues"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foo"), "group"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/group/foo/-/issues", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Method Not Allowed\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestRouterHandler_optionalSegment(t *testing.T) {
+	r := &Router{}
+
+	r.Handle(http.MethodGet, "/posts/:page<[0-9]+>?", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /posts/:page?")
+	}))
+	r.Handle(
+		http.MethodGet,
+		"/archive(/:year<[0-9]{4}>(/:month:int))",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "GET /archive(/:year(/:month))")
+		}),
+	)
+	r.Handle(http.MethodGet, "/static(/*filepath)", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /static(/*filepath)")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /posts/:page?"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "page"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/posts/2", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /posts/:page?"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "page"), "2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/archive", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /archive(/:year(/:month))"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "year"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "month"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/archive/2024", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /archive(/:year(/:month))"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "year"), "2024"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "month"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/archive/2024/05", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /archive(/:year(/:month))"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "year"), "2024"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "month"), "05"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/static", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /static(/*filepath)"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "filepath"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/static/js", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /static(/*filepath)"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "filepath"), "js"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/archive/abc", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	}
 }
 
-func TestRouterHandler(t *testing.T) {
-	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(rw, req.Host == "www.example.com")
-	})
-	mf := MiddlewareFunc(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(
-			rw http.ResponseWriter,
-			req *http.Request,
-		) {
-			req.Host = "www.example.com"
-			next.ServeHTTP(rw, req)
-		})
-	})
+func TestRouterHandler_static_param(t *testing.T) {
+	r := &Router{}
+	r.Handle(http.MethodGet, "/foo", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo")
+	}))
+	r.Handle(http.MethodGet, "/foo/:bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo/:bar")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo/:bar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
 
+func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
 	r := &Router{}
-	r.Handle("", "/", h, mf)
+	r.Handle(http.MethodGet, "/", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /")
+	}))
+	r.Handle(http.MethodGet, "/foo", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo")
+	}))
+	r.Handle(http.MethodGet, "/bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /bar")
+	}))
+	r.Handle(http.MethodGet, "/foobar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foobar")
+	}))
+	r.Handle(http.MethodGet, "/:foobar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /:foobar")
+	}))
+	r.Handle(http.MethodGet, "/foo/:bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo/:bar")
+	}))
+	r.Handle(http.MethodGet, "/foo:bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo:bar")
+	}))
+	r.Handle(http.MethodGet, "/:foo/:bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /:foo/:bar")
+	}))
+	r.Handle(http.MethodGet, "/:foo/foobar/:bar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /:foo/foobar/:bar")
+	}))
+	r.Handle(http.MethodGet, "/:foo/foobar", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /:foo/foobar")
+	}))
+	r.Handle(http.MethodGet, "/foobar*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foobar*")
+	}))
+	r.Handle(http.MethodGet, "/foobar/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foobar/*")
+	}))
+	r.Handle(http.MethodGet, "/foo/:bar/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo/:bar/*")
+	}))
+	r.Handle(http.MethodGet, "/foo:bar/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /foo:bar/*")
+	}))
+	r.Handle(http.MethodGet, "/:foo/:bar/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /:foo/:bar/*")
+	}))
+
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rec := httptest.NewRecorder()
 	mh, req := r.Handler(req)
 	mh.ServeHTTP(rec, req)
-	recr := rec.Result()
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foo"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/bar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /bar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
 	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "true"; string(b) != want {
+	} else if want := "GET /foobar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 
-	r = &Router{}
-	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/barfoo", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
+	} else if want := "GET /:foobar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foobar"), "barfoo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r = &Router{}
-	sr := &Router{
-		Parent:     r,
-		PathPrefix: "/foo",
-	}
-	sr.Handle("", "/", h, mf)
 	req = httptest.NewRequest(http.MethodGet, "/foo/", nil)
 	rec = httptest.NewRecorder()
-	mh, req = sr.Handler(req)
+	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
 	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "true"; string(b) != want {
+	} else if want := "GET /foo/:bar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "bar"), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r = &Router{}
-	r.Handle("", "/", h, mf)
-	req = httptest.NewRequest(http.MethodGet, "/", nil)
-	req.URL.Path = ""
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
+	} else if want := "GET /foo/:bar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r = &Router{}
-	r.Handle("", "/", h, mf)
-	req = httptest.NewRequest(http.MethodGet, "/?foo=bar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/fooobar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -270,73 +3267,47 @@ func TestRouterHandler(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "true"; string(b) != want {
+	} else if want := "GET /foo:bar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := req.URL.Query().Get("foo"), "bar"; got != want {
+	} else if got, want := PathParam(req, "bar"), "obar"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
-}
-
-func TestRouterHandler_static(t *testing.T) {
-	r := &Router{}
-	r.Handle(http.MethodGet, "/", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /")
-	}))
-	r.Handle("custom", "/foo", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "custom /foo")
-	}))
-	r.Handle("", "/foobar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "_ /foobar")
-	}))
-	r.Handle(http.MethodGet, "/foo/bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo/bar")
-	}))
-	r.Handle(http.MethodGet, "/foo/bar/", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo/bar/")
-	}))
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	rec := httptest.NewRecorder()
-	mh, req := r.Handler(req)
+	req = httptest.NewRequest(http.MethodGet, "/bar/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
-	recr := rec.Result()
+	recr = rec.Result()
 	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /"; string(b) != want {
+	} else if want := "GET /:foo/:bar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "bar"), "foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "//", nil)
+	req = httptest.NewRequest(http.MethodGet, "/bar/foobar/foo", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
+	} else if want := "GET /:foo/foobar/:bar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "bar"), "foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest("custom", "/foo", nil)
+	req = httptest.NewRequest(http.MethodGet, "/bar/foobar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -345,49 +3316,43 @@ func TestRouterHandler_static(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "custom /foo"; string(b) != want {
+	} else if want := "GET /:foo/foobar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest("bar", "/foo", nil)
+	req = httptest.NewRequest(http.MethodGet, "/foobarfoobar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Method Not Allowed\n"; string(b) != want {
+	} else if want := "GET /foobar*"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	for _, method := range []string{
-		http.MethodGet,
-		http.MethodHead,
-		http.MethodPost,
-		http.MethodPut,
-		http.MethodPatch,
-		http.MethodDelete,
-		http.MethodConnect,
-		http.MethodOptions,
-		http.MethodTrace,
-	} {
-		req = httptest.NewRequest(method, "/foobar", nil)
-		rec = httptest.NewRecorder()
-		mh, req = r.Handler(req)
-		mh.ServeHTTP(rec, req)
-		recr = rec.Result()
-		if want := http.StatusOK; recr.StatusCode != want {
-			t.Errorf("got %d, want %d", recr.StatusCode, want)
-		} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-			t.Fatalf("unexpected error %q", err)
-		} else if want := "_ /foobar"; string(b) != want {
-			t.Errorf("got %q, want %q", b, want)
-		}
+	req = httptest.NewRequest(http.MethodGet, "/foobar/foobar", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /foobar/*"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/foo/bar/foobar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -396,11 +3361,15 @@ func TestRouterHandler_static(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo/bar"; string(b) != want {
+	} else if want := "GET /foo/:bar/*"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/foofoobar/foobar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -409,35 +3378,77 @@ func TestRouterHandler_static(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo/bar/"; string(b) != want {
+	} else if want := "GET /foo:bar/*"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "bar"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar/foo", nil)
+	req = httptest.NewRequest(http.MethodGet, "/bar/foo/foobar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
+	} else if want := "GET /:foo/:bar/*"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "bar"), "foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 }
 
-func TestRouterHandler_param(t *testing.T) {
+func TestRouterHandler_host(t *testing.T) {
 	r := &Router{}
+	r.Handle(http.MethodGet, "/users", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /users")
+	}))
 
-	r.Handle(http.MethodGet, "/:foobar", http.HandlerFunc(func(
+	tr := &Router{Parent: r, Host: "{tenant}.example.com"}
+	tr.Handle(http.MethodGet, "/users/:id", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /:foobar")
+		fmt.Fprint(rw, "GET {tenant}/users/:id")
+	}))
+	tr.Sub("/admin").Handle(http.MethodGet, "", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET {tenant}/admin")
 	}))
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ar := r.Sub("")
+	ar.Host = "API.example.com"
+	ar.Handle(http.MethodGet, "/users", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET api.example.com/users")
+	}))
+
+	er := r.Sub("")
+	er.Host = "example.{tld}"
+	er.Handle(http.MethodGet, "/users", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET example.{tld}/users")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Host = "acme.example.com"
 	rec := httptest.NewRecorder()
 	mh, req := r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -446,39 +3457,32 @@ func TestRouterHandler_param(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foobar"; string(b) != want {
+	} else if want := "GET {tenant}/users/:id"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foobar"), ""; got != want {
+	} else if got, want := HostParam(req, "tenant"), "acme"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := PathParam(req, "id"), "1"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "//", nil)
-	rec = httptest.NewRecorder()
-	mh, req = r.Handler(req)
-	mh.ServeHTTP(rec, req)
-	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
-		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	}
-
-	req = httptest.NewRequest(http.MethodHead, "/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Host = "Acme.Example.com:8080"
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Method Not Allowed\n"; string(b) != want {
+	} else if want := "GET {tenant}/admin"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := HostParam(req, "tenant"), "acme"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Host = "api.example.com:8080"
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -487,33 +3491,24 @@ func TestRouterHandler_param(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foobar"; string(b) != want {
+	} else if want := "GET api.example.com/users"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foobar"), "foobar"; got != want {
+	} else if got, want := HostParam(req, "tenant"), ""; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Host = "api.example.com"
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
 	if want := http.StatusNotFound; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
 	}
 
-	r.Handle(http.MethodGet, "/foo:bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo:bar")
-	}))
-
-	req = httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Host = "example.org"
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -522,13 +3517,14 @@ func TestRouterHandler_param(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo:bar"; string(b) != want {
+	} else if want := "GET example.{tld}/users"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), ""; got != want {
+	} else if got, want := HostParam(req, "tld"), "org"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Host = "example.co.uk"
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -537,21 +3533,26 @@ func TestRouterHandler_param(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo:bar"; string(b) != want {
+	} else if want := "GET /users"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r.Handle(http.MethodGet, "/:foo/:bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /:foo/:bar")
-	}))
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Host = "a.b.example.com"
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "GET /users"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
-	req = req.WithContext(&dataContext{d: &data{}})
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Host = "[::1]:8080"
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -560,26 +3561,130 @@ func TestRouterHandler_param(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foo/:bar"; string(b) != want {
+	} else if want := "GET /users"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foo"), "foo"; got != want {
-		t.Errorf("got %q, want %q", got, want)
-	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Host = "example.com"
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	}
 }
 
-func TestRouterHandler_wildcardParam(t *testing.T) {
+func TestRouterHandler_matcher(t *testing.T) {
 	r := &Router{}
+	r.Handle(
+		http.MethodGet,
+		"/users/:id",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "v2")
+		}),
+		MatchHeader("X-API-Version", "2"),
+	)
+	r.Handle(
+		http.MethodGet,
+		"/users/:id",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "v3")
+		}),
+		MatchQuery("version", "3"),
+	)
+	r.Handle(http.MethodGet, "/users/:id", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "v1")
+	}))
+	r.Handle(http.MethodGet, "/posts", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /posts")
+	}))
+	r.Handle(
+		http.MethodPost,
+		"/posts",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "POST /posts")
+		}),
+		MatchHeader("Content-Type", "application/json"),
+	)
+	r.Handle("FOO", "/posts", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "FOO /posts")
+	}))
+	r.Handle(
+		"FOO",
+		"/posts",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "FOO /posts v2")
+		}),
+		MatchHeader("X-API-Version", "2"),
+	)
+	r.Handle(http.MethodGet, "/files/*", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "GET /files/*")
+	}))
+	r.Handle(
+		http.MethodGet,
+		"/files/:name",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "GET /files/:name")
+		}),
+		MatchHeader("X-API-Version", "2"),
+	)
+	r.Handle(
+		"",
+		"/any",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "/any v2")
+		}),
+		MatchQuery("version", "2"),
+	)
+	r.Handle("", "/any", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "/any")
+	}))
 
-	r.Handle(http.MethodGet, "/*", http.HandlerFunc(func(
+	ar := r.Sub("/admin", MatchHeader("X-Admin", "1"))
+	ar.Handle(http.MethodGet, "", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /*")
+		fmt.Fprint(rw, "GET /admin")
 	}))
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("X-API-Version", "2")
 	rec := httptest.NewRecorder()
 	mh, req := r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -588,13 +3693,11 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "v2"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "//", nil)
+	req = httptest.NewRequest(http.MethodGet, "/users/1?version=3", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -603,26 +3706,25 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "v3"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "/"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodHead, "/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/users/1?version=3", nil)
+	req.Header.Set("X-API-Version", "2")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Method Not Allowed\n"; string(b) != want {
+	} else if want := "v2"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/users/1", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -631,13 +3733,12 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "v1"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	req = httptest.NewRequest(http.MethodPost, "/posts", nil)
+	req.Header.Set("Content-Type", "application/json")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -646,28 +3747,30 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "POST /posts"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foobar/"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar//", nil)
+	req = httptest.NewRequest(http.MethodPost, "/posts", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusNotFound; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foobar//"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodPut, "/posts", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	}
+
+	req = httptest.NewRequest("FOO", "/posts", nil)
+	req.Header.Set("X-API-Version", "2")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -676,13 +3779,11 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "FOO /posts v2"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foo/bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar/", nil)
+	req = httptest.NewRequest("FOO", "/posts", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -691,13 +3792,12 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "FOO /posts"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foo/bar/"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar//", nil)
+	req = httptest.NewRequest(http.MethodGet, "/files/foo", nil)
+	req.Header.Set("X-API-Version", "2")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -706,20 +3806,11 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "GET /files/:name"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foo/bar//"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r.Handle(http.MethodGet, "/foobar*", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foobar*")
-	}))
-
-	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/files/foo", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -728,13 +3819,11 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar*"; string(b) != want {
+	} else if want := "GET /files/*"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/any?version=2", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -743,13 +3832,11 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar*"; string(b) != want {
+	} else if want := "/any v2"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "/"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar//", nil)
+	req = httptest.NewRequest(http.MethodGet, "/any", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -758,20 +3845,12 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar*"; string(b) != want {
+	} else if want := "/any"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "//"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r.Handle(http.MethodGet, "/foobar/*", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foobar/*")
-	}))
-
-	req = httptest.NewRequest(http.MethodGet, "/foobar/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("X-Admin", "1")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -780,42 +3859,109 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar/*"; string(b) != want {
+	} else if want := "GET /admin"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r.Handle(http.MethodGet, "/foobar2/*", http.HandlerFunc(func(
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	}
+}
+
+func TestRouterHandler_contentNegotiation(t *testing.T) {
+	r := &Router{}
+	r.Handle(
+		http.MethodGet,
+		"/reports/:id",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "json")
+		}),
+		Produces("application/json"),
+	)
+	r.Handle(
+		http.MethodGet,
+		"/reports/:id",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "csv")
+		}),
+		Produces("text/csv"),
+	)
+	r.Handle(
+		http.MethodPost,
+		"/reports",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			rw.WriteHeader(http.StatusCreated)
+			fmt.Fprint(rw, "created")
+		}),
+		Consumes("application/json"),
+		Produces("application/json"),
+	)
+	r.Handle(http.MethodGet, "/items", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /foobar2/*")
+		fmt.Fprint(rw, "plain")
 	}))
+	r.Handle(
+		http.MethodGet,
+		"/items",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "json")
+		}),
+		Produces("application/json"),
+	)
+	r.Handle("", "/upload", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "upload")
+	}), Consumes("image/*"))
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar2/", nil)
-	rec = httptest.NewRecorder()
-	mh, req = r.Handler(req)
+	vr := r.Sub("/v2", Produces("application/json", "text/csv"))
+	vr.Handle(
+		http.MethodGet,
+		"/export",
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "export")
+		}),
+		Produces("text/csv"),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
 	mh.ServeHTTP(rec, req)
-	recr = rec.Result()
+	recr := rec.Result()
 	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar2/*"; string(b) != want {
+	} else if want := "json"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r.Handle(http.MethodGet, "/foo/bar/*", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo/bar/*")
-	}))
-
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+	req.Header.Set("Accept", "text/csv")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -824,92 +3970,85 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo/bar/*"; string(b) != want {
+	} else if want := "csv"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+	req.Header.Set("Accept", "text/csv;q=0.5, application/*")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMovedPermanently; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if loc, err := recr.Location(); err != nil {
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if got, want := loc.String(), "/foo/bar/"; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	} else if want := "json"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar?foo=bar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, text/*")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMovedPermanently; recr.StatusCode != want {
+	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if loc, err := recr.Location(); err != nil {
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if got, want := loc.String(), "/foo/bar/?foo=bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	} else if want := "csv"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
 	}
 
-	req = httptest.NewRequest(http.MethodHead, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+	req.Header.Set("Accept", "text/html")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMovedPermanently; recr.StatusCode != want {
+	if want := http.StatusNotAcceptable; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if loc, err := recr.Location(); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if got, want := loc.String(), "/foo/bar/"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	r.Handle(http.MethodGet, "/foo/bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "_ /foo/bar")
-	}))
-
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodPost, "/reports", nil)
+	req.Header.Set("Content-Type", "application/json")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusCreated; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "_ /foo/bar"; string(b) != want {
+	} else if want := "created"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 
-	req = httptest.NewRequest(http.MethodHead, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodPost, "/reports", nil)
+	req.Header.Set("Content-Type", "text/csv")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+	if want := http.StatusUnsupportedMediaType; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "Method Not Allowed\n"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
 	}
 
-	r.Handle("custom", "/barfoo/*", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "custom /barfoo/*")
-	}))
+	req = httptest.NewRequest(http.MethodPost, "/reports", nil)
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Accept", "text/html")
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusUnsupportedMediaType; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	}
 
-	req = httptest.NewRequest("custom", "/barfoo/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set("Accept", "application/json")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -918,54 +4057,12 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "custom /barfoo/*"; string(b) != want {
+	} else if want := "json"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
-	}
-
-	r.Handle("", "/barfoo/*", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "_ /barfoo/*")
-	}))
-
-	for _, method := range []string{
-		http.MethodGet,
-		http.MethodHead,
-		http.MethodPost,
-		http.MethodPut,
-		http.MethodPatch,
-		http.MethodDelete,
-		http.MethodConnect,
-		http.MethodOptions,
-		http.MethodTrace,
-	} {
-		req = httptest.NewRequest(method, "/barfoo/", nil)
-		rec = httptest.NewRecorder()
-		mh, req = r.Handler(req)
-		mh.ServeHTTP(rec, req)
-		recr = rec.Result()
-		if want := http.StatusOK; recr.StatusCode != want {
-			t.Errorf("got %d, want %d", recr.StatusCode, want)
-		} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-			t.Fatalf("unexpected error %q", err)
-		} else if want := "_ /barfoo/*"; string(b) != want {
-			t.Errorf("got %q, want %q", b, want)
-		} else if got, want := PathParam(req, "*"), ""; got != want {
-			t.Errorf("got %q, want %q", got, want)
-		}
 	}
 
-	r.Handle(http.MethodGet, "/bar/foo*", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /bar/foo*")
-	}))
-
-	req = httptest.NewRequest(http.MethodGet, "/bar/foo", nil)
+	req = httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set("Accept", "text/html")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -974,13 +4071,12 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /bar/foo*"; string(b) != want {
+	} else if want := "plain"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar/", nil)
+	req = httptest.NewRequest(http.MethodGet, "/v2/export", nil)
+	req.Header.Set("Accept", "text/csv;q=0.5, application/json")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -989,42 +4085,22 @@ func TestRouterHandler_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /*"; string(b) != want {
+	} else if want := "export"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "bar/"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
-}
-
-func TestRouterHandler_static_param(t *testing.T) {
-	r := &Router{}
-	r.Handle(http.MethodGet, "/foo", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo")
-	}))
-	r.Handle(http.MethodGet, "/foo/:bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo/:bar")
-	}))
 
-	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
-	rec := httptest.NewRecorder()
-	mh, req := r.Handler(req)
+	req = httptest.NewRequest(http.MethodGet, "/v2/export", nil)
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
-	recr := rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	recr = rec.Result()
+	if want := http.StatusNotAcceptable; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodPut, "/upload", nil)
+	req.Header.Set("Content-Type", "image/png")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -1033,120 +4109,99 @@ func TestRouterHandler_static_param(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo/:bar"; string(b) != want {
+	} else if want := "upload"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar", nil)
+	req = httptest.NewRequest(http.MethodPut, "/upload", nil)
+	req.Header.Set("Content-Type", "text/plain")
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusUnsupportedMediaType; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
 	}
 }
 
-func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
-	r := &Router{}
-	r.Handle(http.MethodGet, "/", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /")
-	}))
+func TestRouterHandler_autoHEAD(t *testing.T) {
+	r := &Router{AutoHEAD: true}
 	r.Handle(http.MethodGet, "/foo", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /foo")
-	}))
-	r.Handle(http.MethodGet, "/bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /bar")
-	}))
-	r.Handle(http.MethodGet, "/foobar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foobar")
-	}))
-	r.Handle(http.MethodGet, "/:foobar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /:foobar")
-	}))
-	r.Handle(http.MethodGet, "/foo/:bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo/:bar")
-	}))
-	r.Handle(http.MethodGet, "/foo:bar", http.HandlerFunc(func(
-		rw http.ResponseWriter,
-		req *http.Request,
-	) {
-		fmt.Fprint(rw, "GET /foo:bar")
+		rw.Header().Set("Foo", "bar")
+		fmt.Fprint(rw, "foo")
+		fmt.Fprint(rw, "bar")
 	}))
-	r.Handle(http.MethodGet, "/:foo/:bar", http.HandlerFunc(func(
+	r.Handle(http.MethodGet, "/bar", http.NotFoundHandler())
+	r.Handle(http.MethodHead, "/bar", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /:foo/:bar")
+		rw.Header().Set("Content-Length", "")
 	}))
-	r.Handle(http.MethodGet, "/:foo/foobar/:bar", http.HandlerFunc(func(
+	r.Handle(http.MethodGet, "/baz", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /:foo/foobar/:bar")
+		rw.Header().Set("Content-Length", "100")
 	}))
-	r.Handle(http.MethodGet, "/:foo/foobar", http.HandlerFunc(func(
+	r.Handle(http.MethodGet, "/qux", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /:foo/foobar")
+		rw.WriteHeader(http.StatusNoContent)
 	}))
-	r.Handle(http.MethodGet, "/foobar*", http.HandlerFunc(func(
+	r.Handle(http.MethodGet, "/quux", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /foobar*")
+		rw.WriteHeader(http.StatusCreated)
+		rw.WriteHeader(http.StatusOK)
+		fmt.Fprint(rw, "ok")
 	}))
-	r.Handle(http.MethodGet, "/foobar/*", http.HandlerFunc(func(
+	r.Handle("", "/any", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /foobar/*")
+		rw.Header().Set("Content-Length", "")
 	}))
-	r.Handle(http.MethodGet, "/foo/:bar/*", http.HandlerFunc(func(
+	r.Handle(http.MethodGet, "/any", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /foo/:bar/*")
+		fmt.Fprint(rw, "foobar")
 	}))
-	r.Handle(http.MethodGet, "/foo:bar/*", http.HandlerFunc(func(
+	r.Handle(
+		"",
+		"/corge",
+		http.NotFoundHandler(),
+		MatchHeader("X-Foo", "bar"),
+	)
+	r.Handle(http.MethodGet, "/corge", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /foo:bar/*")
+		fmt.Fprint(rw, "foobar")
 	}))
-	r.Handle(http.MethodGet, "/:foo/:bar/*", http.HandlerFunc(func(
+	r.Handle(
+		http.MethodGet,
+		"/grault",
+		http.NotFoundHandler(),
+		MatchHeader("X-Foo", "bar"),
+	)
+	r.Handle(http.MethodGet, "/flush", http.HandlerFunc(func(
 		rw http.ResponseWriter,
 		req *http.Request,
 	) {
-		fmt.Fprint(rw, "GET /:foo/:bar/*")
+		fmt.Fprint(rw, "foo")
+		rw.(http.Flusher).Flush()
+		fmt.Fprint(rw, "bar")
+		rw.(http.Flusher).Flush()
 	}))
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req := httptest.NewRequest(http.MethodHead, "/foo", nil)
 	rec := httptest.NewRecorder()
 	mh, req := r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -1155,8 +4210,12 @@ func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /"; string(b) != want {
+	} else if want := ""; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := recr.ContentLength, int64(6); got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := recr.Header.Get("Foo"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 
 	req = httptest.NewRequest(http.MethodGet, "/foo", nil)
@@ -1168,11 +4227,11 @@ func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo"; string(b) != want {
+	} else if want := "foobar"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar", nil)
+	req = httptest.NewRequest(http.MethodHead, "/bar", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -1181,11 +4240,13 @@ func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /bar"; string(b) != want {
+	} else if want := ""; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := recr.ContentLength, int64(-1); got != want {
+		t.Errorf("got %d, want %d", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar", nil)
+	req = httptest.NewRequest(http.MethodHead, "/baz", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -1194,41 +4255,43 @@ func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar"; string(b) != want {
+	} else if want := ""; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
+	} else if got, want := recr.ContentLength, int64(100); got != want {
+		t.Errorf("got %d, want %d", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/barfoo", nil)
+	req = httptest.NewRequest(http.MethodHead, "/qux", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusNoContent; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foobar"; string(b) != want {
+	} else if want := ""; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foobar"), "barfoo"; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := recr.ContentLength, int64(-1); got != want {
+		t.Errorf("got %d, want %d", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	req = httptest.NewRequest(http.MethodHead, "/quux", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusCreated; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo/:bar"; string(b) != want {
+	} else if want := ""; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), ""; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := recr.ContentLength, int64(2); got != want {
+		t.Errorf("got %d, want %d", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	req = httptest.NewRequest(http.MethodHead, "/any", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -1237,28 +4300,39 @@ func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo/:bar"; string(b) != want {
+	} else if want := ""; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := recr.ContentLength, int64(-1); got != want {
+		t.Errorf("got %d, want %d", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/fooobar", nil)
+	req = httptest.NewRequest(http.MethodHead, "/corge", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
 	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo:bar"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), "obar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := recr.ContentLength, int64(6); got != want {
+		t.Errorf("got %d, want %d", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar/foo", nil)
+	req = httptest.NewRequest(http.MethodHead, "/grault", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if got, want := r.Explain(
+		http.MethodHead,
+		"/grault",
+	), "matchers of node \"grault\" (static) reject the request "+
+		"with status 404\n"; !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to contain %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/flush", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
@@ -1267,128 +4341,510 @@ func TestRouterHandler_static_param_wildcardParam(t *testing.T) {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foo/:bar"; string(b) != want {
+	} else if want := ""; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
-	} else if got, want := PathParam(req, "bar"), "foo"; got != want {
-		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := recr.ContentLength, int64(-1); got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if !rec.Flushed {
+		t.Error("want flushed")
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar/foobar/foo", nil)
+	r = &Router{}
+	r.Handle(http.MethodGet, "/foo", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "foobar")
+	}))
+
+	req = httptest.NewRequest(http.MethodHead, "/foo", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foo/foobar/:bar"; string(b) != want {
+	} else if want := "Method Not Allowed\n"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
+	}
+}
+
+func TestRouterHandler_allowedMethods(t *testing.T) {
+	r := &Router{}
+	r.Handle(http.MethodGet, "/foo", http.NotFoundHandler())
+	r.Handle(http.MethodPost, "/foo", http.NotFoundHandler())
+	r.Handle("FOO", "/foo", http.NotFoundHandler())
+	r.Handle(http.MethodDelete, "/bar/:id", http.NotFoundHandler())
+	r.Handle(http.MethodOptions, "/baz", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		rw.Header().Set("Allow", "custom")
+	}))
+	r.Handle("", "/qux", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+	}))
+
+	req := httptest.NewRequest(http.MethodPut, "/foo", nil)
+	rec := httptest.NewRecorder()
+	mh, req := r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if got, want := recr.Header.Get("Allow"), "GET, "+
+		"POST, FOO"; got != want {
 		t.Errorf("got %q, want %q", got, want)
-	} else if got, want := PathParam(req, "bar"), "foo"; got != want {
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/foo", nil)
+	rec = httptest.NewRecorder()
+	mh, req = r.Handler(req)
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if got, want := recr.Header.Get("Allow"), "GET, "+
+		"POST, FOO"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar/foobar", nil)
+	r.AutoHEAD = true
+	r.AutoOPTIONS = true
+
+	req = httptest.NewRequest(http.MethodPut, "/foo", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foo/foobar"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
+	} else if got, want := recr.Header.Get("Allow"), "GET, "+
+		"HEAD, POST, OPTIONS, FOO"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobarfoobar", nil)
+	req = httptest.NewRequest(http.MethodOptions, "/foo", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusNoContent; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar*"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+	} else if got, want := recr.Header.Get("Allow"), "GET, "+
+		"HEAD, POST, OPTIONS, FOO"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foobar/foobar", nil)
+	req = httptest.NewRequest(http.MethodOptions, "/bar/1", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusNoContent; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foobar/*"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+	} else if got, want := recr.Header.Get("Allow"), "DELETE, "+
+		"OPTIONS"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foo/bar/foobar", nil)
+	req = httptest.NewRequest(http.MethodOptions, "/baz", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
 	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo/:bar/*"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), "bar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
-	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+	} else if got, want := recr.Header.Get("Allow"), "custom"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/foofoobar/foobar", nil)
+	req = httptest.NewRequest(http.MethodOptions, "/qux", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
 	if want := http.StatusOK; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /foo:bar/*"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "bar"), "foobar"; got != want {
-		t.Errorf("got %q, want %q", got, want)
-	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+	} else if got, want := recr.Header.Get("Allow"), ""; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/bar/foo/foobar", nil)
+	req = httptest.NewRequest(http.MethodOptions, "/quux", nil)
 	rec = httptest.NewRecorder()
 	mh, req = r.Handler(req)
 	mh.ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusOK; recr.StatusCode != want {
+	if want := http.StatusNotFound; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
-	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
-		t.Fatalf("unexpected error %q", err)
-	} else if want := "GET /:foo/:bar/*"; string(b) != want {
-		t.Errorf("got %q, want %q", b, want)
-	} else if got, want := PathParam(req, "foo"), "bar"; got != want {
+	} else if got, want := recr.Header.Get("Allow"), ""; got != want {
 		t.Errorf("got %q, want %q", got, want)
-	} else if got, want := PathParam(req, "bar"), "foo"; got != want {
+	}
+}
+
+func TestRouterHandler_routePattern(t *testing.T) {
+	r := &Router{AutoHEAD: true, AutoOPTIONS: true}
+	r.Handle(http.MethodGet, "/", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/:id:int", http.NotFoundHandler())
+	r.Handle(http.MethodPost, "/users/:id<[0-9]+>", http.NotFoundHandler())
+	r.Handle("", "/posts/:slug.:ext", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/files/*filepath", http.NotFoundHandler())
+	r.Sub("/v1").Handle(http.MethodPut, "/*", http.NotFoundHandler())
+	hr := &Router{Parent: r, Host: "{tenant}.example.com"}
+	hr.Handle(http.MethodGet, "/users/:id:int", http.NotFoundHandler())
+
+	for _, tt := range []struct {
+		method      string
+		target      string
+		wantPattern string
+		wantMethod  string
+	}{
+		{
+			method:      http.MethodGet,
+			target:      "/users/1",
+			wantPattern: "/users/:id:int",
+			wantMethod:  http.MethodGet,
+		},
+		{
+			method:      http.MethodPost,
+			target:      "/users/1",
+			wantPattern: "/users/:id<[0-9]+>",
+			wantMethod:  http.MethodPost,
+		},
+		{
+			method:      http.MethodHead,
+			target:      "/users/1",
+			wantPattern: "/users/:id:int",
+			wantMethod:  http.MethodGet,
+		},
+		{
+			method:      http.MethodOptions,
+			target:      "/users/1",
+			wantPattern: "/users/:id:int",
+			wantMethod:  http.MethodOptions,
+		},
+		{
+			method:      "FOO",
+			target:      "/posts/foo.html",
+			wantPattern: "/posts/:slug.:ext",
+			wantMethod:  "",
+		},
+		{
+			method:      http.MethodGet,
+			target:      "/files/foo/bar",
+			wantPattern: "/files/*filepath",
+			wantMethod:  http.MethodGet,
+		},
+		{
+			method:      http.MethodPut,
+			target:      "/v1/foo",
+			wantPattern: "/v1/*",
+			wantMethod:  http.MethodPut,
+		},
+		{
+			method:      http.MethodGet,
+			target:      "http://foo.example.com/users/1",
+			wantPattern: "/users/:id:int",
+			wantMethod:  http.MethodGet,
+		},
+		{
+			method: http.MethodGet,
+			target: "/files",
+		},
+		{
+			method: http.MethodGet,
+			target: "/posts",
+		},
+		{
+			method: http.MethodDelete,
+			target: "/users/1",
+		},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		_, req = r.Handler(req)
+		if got, want := RoutePattern(req), tt.wantPattern; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		} else if got, want := RouteMethod(req),
+			tt.wantMethod; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	_, req := r.Handler(httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := RoutePattern(req), ""; got != want {
 		t.Errorf("got %q, want %q", got, want)
-	} else if got, want := PathParam(req, "*"), "foobar"; got != want {
+	}
+
+	_, req = r.Handler(req.WithContext(Context()))
+	if got, want := RoutePattern(req), "/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := RouteMethod(req), http.MethodGet; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_context(t *testing.T) {
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(PathParam(req, "id")))
+	})
+
+	r := &Router{AutoOPTIONS: true}
+	hr := &Router{Parent: r, Host: "{tenant}.example.com"}
+	hr.Handle(http.MethodGet, "/users/:id", h)
+	r.Handle(http.MethodGet, "/plain", h)
+	r.Handle(http.MethodGet, "/files/*", h)
+	r.Handle(http.MethodPost, "/only", h)
+	r.Handle(http.MethodGet, "/posts/:id", h)
+
+	ctx := Context()
+	for _, tt := range []struct {
+		method string
+		target string
+		want   string
+	}{
+		{
+			http.MethodGet,
+			"http://acme.example.com/users/1",
+			"/users/:id GET acme [] 1",
+		},
+		{http.MethodGet, "http://acme.example.com/foo", "   [] "},
+		{http.MethodGet, "http://acme.example.com/users/1", ""},
+		{http.MethodGet, "/plain", "/plain GET  [] "},
+		{http.MethodPost, "/only", "/only POST  [] "},
+		{http.MethodGet, "/only", "   [POST OPTIONS] "},
+		{http.MethodGet, "/files", "   [] "},
+		{
+			http.MethodOptions,
+			"/plain",
+			"/plain OPTIONS  [GET OPTIONS] ",
+		},
+		{http.MethodGet, "/plain", "/plain GET  [] "},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		h, req := r.Handler(req.WithContext(ctx))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if tt.want == "" {
+			continue
+		}
+
+		got := fmt.Sprint(
+			RoutePattern(req), " ",
+			RouteMethod(req), " ",
+			HostParam(req, "tenant"), " ",
+			AllowedMethods(req), " ",
+			PathParam(req, "id"),
+		)
+		if got != tt.want {
+			t.Errorf("%s %s: got %q, want %q",
+				tt.method, tt.target, got, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.Match(http.MethodGet, "/posts/1").Handler.ServeHTTP(
+		rec,
+		httptest.NewRequest(http.MethodGet, "/posts/1", nil),
+	)
+	if got, want := rec.Body.String(), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_allocs(t *testing.T) {
+	r := &Router{}
+	r.Handle(http.MethodGet, "/foo/bar", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/foo/:bar", http.NotFoundHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	if got := testing.AllocsPerRun(100, func() {
+		r.Handler(req)
+	}); got != 0 {
+		t.Errorf("got %v allocs, want 0", got)
+	}
+
+	req = req.WithContext(Context())
+	if got := testing.AllocsPerRun(100, func() {
+		r.Handler(req)
+	}); got != 0 {
+		t.Errorf("got %v allocs, want 0", got)
+	} else if got, want := RoutePattern(req), "/foo/bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterHandler_pathParamNames(t *testing.T) {
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(strings.Join(PathParamNames(req), ",") + " " +
+			strings.Join(PathParamValues(req), ",") + " " +
+			RoutePattern(req)))
+	})
+
+	r := &Router{AutoHEAD: true, Concurrent: true}
+	r.Handle(http.MethodGet, "/users/:id", h)
+	r.Handle(http.MethodDelete, "/users/:uid", h)
+	r.Handle(http.MethodPost, "/users/:id", h)
+	r.Handle("", "/users/:any", h)
+	r.Handle(http.MethodGet, "/posts/:pid/comments", h)
+	r.Handle(http.MethodGet, "/posts/:id", h)
+	r.Handle("FOO", "/files/*path", h)
+	r.Handle("BAR", "/files/*name", h)
+
+	for _, tt := range []struct {
+		method string
+		target string
+		want   string
+	}{
+		{http.MethodGet, "/users/1", "id 1 /users/:id"},
+		{http.MethodHead, "/users/1", "any 1 /users/:any"},
+		{http.MethodDelete, "/users/1", "uid 1 /users/:uid"},
+		{http.MethodPost, "/users/1", "id 1 /users/:id"},
+		{http.MethodPut, "/users/1", "any 1 /users/:any"},
+		{http.MethodGet, "/posts/1", "id 1 /posts/:id"},
+		{
+			http.MethodGet,
+			"/posts/1/comments",
+			"pid 1 /posts/:pid/comments",
+		},
+		{"FOO", "/files/a/b", "*path a/b /files/*path"},
+		{"BAR", "/files/a/b", "*name a/b /files/*name"},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got := rec.Body.String(); tt.method != http.MethodHead &&
+			got != tt.want {
+			t.Errorf("%s %s: got %q, want %q",
+				tt.method, tt.target, got, tt.want)
+		}
+
+		mr := r.Match(tt.method, tt.target)
+		fs := strings.Fields(tt.want)
+		if got, want := mr.Pattern, fs[2]; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		} else if got, want := strings.Join(
+			mr.PathParamNames,
+			",",
+		), fs[0]; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	if got, want := r.Match(
+		http.MethodPatch,
+		"/posts/1",
+	).Pattern, "/posts/:id"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	rn := r.loadTable().routeTree.node(
+		"/users/:",
+		[]*pathParamConstraint{nil},
+	)
+	if got, want := len(rn.methodPathParamNames), 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	r.Remove(http.MethodDelete, "/users/:uid")
+	r.Handle(http.MethodDelete, "/users/:id", h)
+	r.Handle(http.MethodPut, "/users/:id", h)
+	rn = r.loadTable().routeTree.node(
+		"/users/:",
+		[]*pathParamConstraint{nil},
+	)
+	if got, want := len(rn.methodPathParamNames), 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := r.Match(
+		http.MethodDelete,
+		"/users/1",
+	).Pattern, "/users/:id"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := r.TryHandle(
+		http.MethodPut,
+		"/users/:uid",
+		h,
+		MatchHeader("X-Foo", "1"),
+	); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrConflictingPathParamNames) {
+		t.Errorf("got %q, want %q", err, ErrConflictingPathParamNames)
+	}
+
+	r.Handle(http.MethodPut, "/users/:id", h, MatchHeader("X-Foo", "2"))
+	if got, want := len(rn.methodPathParamNames), 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	r.Remove(http.MethodGet, "/users/:id")
+	r.Remove(http.MethodPost, "/users/:id")
+	r.Remove(http.MethodDelete, "/users/:id")
+	r.Remove(http.MethodPut, "/users/:id")
+	r.Remove("", "/users/:any")
+	r.Handle(http.MethodGet, "/users/:name/posts", h)
+	r.Handle(http.MethodGet, "/users/:uid", h)
+	rn = r.loadTable().routeTree.node(
+		"/users/:",
+		[]*pathParamConstraint{nil},
+	)
+	if got, want := len(rn.methodPathParamNames), 0; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := r.Match(
+		http.MethodGet,
+		"/users/1",
+	).Pattern, "/users/:uid"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	hr := &Router{Parent: r, Host: "api.example.com"}
+	if err := hr.TryHandle(
+		http.MethodGet,
+		"/m/:a",
+		h,
+		MatchHeader("X-Foo", "a"),
+	); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if err := hr.TryHandle(
+		http.MethodGet,
+		"/m/:b",
+		h,
+		MatchHeader("X-Foo", "b"),
+	); err == nil {
+		t.Fatal("expected error")
+	} else if !errors.Is(err, ErrConflictingPathParamNames) {
+		t.Errorf("got %q, want %q", err, ErrConflictingPathParamNames)
+	} else if err := hr.TryHandle(
+		http.MethodGet,
+		"/m/:a",
+		h,
+		MatchHeader("X-Foo", "b"),
+	); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+
+	req := httptest.NewRequest(
+		http.MethodGet,
+		"http://api.example.com/m/1",
+		nil,
+	)
+	req.Header.Set("X-Foo", "a")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if got, want := rec.Body.String(), "a 1 /m/:a"; got != want {
 		t.Errorf("got %q, want %q", got, want)
 	}
+
+	if err := (&Router{Parent: r, Host: "www.example.com"}).TryHandle(
+		http.MethodGet,
+		"/m/:b",
+		h,
+	); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
 }
 
 func TestRouterHandler_fallback(t *testing.T) {
@@ -1995,68 +5451,444 @@ func TestRouterHandler_misc(t *testing.T) {
 	}
 }
 
-func TestRouterServeHTTP(t *testing.T) {
-	r1 := &Router{
+func TestRouterServeHTTP(t *testing.T) {
+	r1 := &Router{
+		NotFoundHandler: http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			http.Error(rw, "r1: not found", http.StatusNotFound)
+		}),
+	}
+	r1.table.Store(&routeTable{
+		routeTree: &routeNode{
+			staticChildren: make([]*routeNode, 255),
+			methodHandlers: &methodHandlers{},
+		},
+		registeredRoutes: map[string]bool{},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	r1.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "r1: not found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r2 := &Router{
+		Parent: r1,
+		NotFoundHandler: http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			http.Error(rw, "r2: not found", http.StatusNotFound)
+		}),
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	r2.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "r1: not found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestRouterMatch(t *testing.T) {
+	r := &Router{}
+
+	mr := r.Match(http.MethodGet, "/")
+	if got, want := mr.Status, MatchNotFound; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if mr.Handler == nil {
+		t.Fatal("unexpected nil")
+	}
+
+	sr := r.Sub("/foo")
+	sr.Handle(http.MethodGet, "", http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		rw.Write([]byte("GET /foo"))
+	}))
+	sr.Handle(http.MethodGet, "/:id:int", http.NotFoundHandler())
+	sr.Handle(
+		http.MethodGet,
+		"/:id:int/:name<[a-z]+>",
+		http.NotFoundHandler(),
+	)
+	r.Handle(http.MethodGet, "/bar/*filepath", http.NotFoundHandler())
+	r.Handle(
+		http.MethodPost,
+		"/baz",
+		http.NotFoundHandler(),
+		Consumes("image/png"),
+	)
+
+	mr = sr.Match(http.MethodGet, "/foo")
+	if got, want := mr.Status, MatchFound; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	} else if got, want := mr.Pattern, "/foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if mr.PathParamNames != nil {
+		t.Errorf("got %v, want nil", mr.PathParamNames)
+	} else if mr.PathParamValues != nil {
+		t.Errorf("got %v, want nil", mr.PathParamValues)
+	} else {
+		rec := httptest.NewRecorder()
+		mr.Handler.ServeHTTP(rec, httptest.NewRequest(
+			http.MethodGet,
+			"/foo",
+			nil,
+		))
+		if got, want := rec.Body.String(), "GET /foo"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		method              string
+		path                string
+		wantStatus          MatchStatus
+		wantPattern         string
+		wantPathParamNames  []string
+		wantPathParamValues []string
+	}{
+		{
+			method:              http.MethodGet,
+			path:                "/foo/1",
+			wantStatus:          MatchFound,
+			wantPattern:         "/foo/:id:int",
+			wantPathParamNames:  []string{"id"},
+			wantPathParamValues: []string{"1"},
+		},
+		{
+			method:              http.MethodGet,
+			path:                "/foo/1/bar",
+			wantStatus:          MatchFound,
+			wantPattern:         "/foo/:id:int/:name<[a-z]+>",
+			wantPathParamNames:  []string{"id", "name"},
+			wantPathParamValues: []string{"1", "bar"},
+		},
+		{
+			method:              http.MethodGet,
+			path:                "/bar/a/b",
+			wantStatus:          MatchFound,
+			wantPattern:         "/bar/*filepath",
+			wantPathParamNames:  []string{"*filepath"},
+			wantPathParamValues: []string{"a/b"},
+		},
+		{
+			method:      http.MethodPost,
+			path:        "/foo/1",
+			wantStatus:  MatchMethodNotAllowed,
+			wantPattern: "/foo/:id:int",
+		},
+		{
+			method:     http.MethodGet,
+			path:       "/foo/bar",
+			wantStatus: MatchNotFound,
+		},
+		{
+			method:     http.MethodGet,
+			path:       "/bar",
+			wantStatus: MatchTSR,
+		},
+		{
+			method:     http.MethodPost,
+			path:       "/baz",
+			wantStatus: MatchUnsupportedMediaType,
+		},
+	} {
+		mr := r.Match(tt.method, tt.path)
+		if got, want := mr.Status, tt.wantStatus; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		} else if mr.Handler == nil {
+			t.Fatal("unexpected nil")
+		} else if got, want := mr.Pattern, tt.wantPattern; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		} else if got, want := len(mr.PathParamNames),
+			len(tt.wantPathParamNames); got != want {
+			t.Errorf("got %d, want %d", got, want)
+		} else if got, want := len(mr.PathParamValues),
+			len(tt.wantPathParamValues); got != want {
+			t.Errorf("got %d, want %d", got, want)
+		} else {
+			for i, want := range tt.wantPathParamNames {
+				if got := mr.PathParamNames[i]; got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			}
+
+			for i, want := range tt.wantPathParamValues {
+				if got := mr.PathParamValues[i]; got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRouterExplain(t *testing.T) {
+	r := &Router{}
+	if got, want := r.Explain(http.MethodGet, "/"), `no routes registered
+result: not found
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/users/:id<[0-9]+>", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/:name/posts", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/files/*filepath", http.NotFoundHandler())
+	r.Handle(
+		http.MethodGet,
+		"/foo",
+		http.NotFoundHandler(),
+		MatchHeader("Foo", "bar"),
+	)
+	r.Handle("", "/bar", http.NotFoundHandler(), Consumes("image/png"))
+
+	sr := r.Sub("")
+	for _, tt := range []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/users/abc/posts",
+			want: `static node "/" (static) matches
+static node "users/" (static) matches
+param node ":" (param <[0-9]+>) rejects "abc"
+param node ":" (param) captures "abc"
+static node "/posts" (static) matches
+node "/posts" (static) has handler for method "GET"
+result: found "/users/:name/posts" name="abc"
+`,
+		},
+		{
+			method: http.MethodGet,
+			path:   "/users/abc/x",
+			want: `static node "/" (static) matches
+static node "users/" (static) matches
+param node ":" (param <[0-9]+>) rejects "abc"
+param node ":" (param) captures "abc"
+static node "/posts" (static) does not match "/x"
+backtrack from node "/posts" (static)
+backtrack from node ":" (param)
+backtrack from node "users/" (static)
+result: not found
+`,
+		},
+		{
+			method: http.MethodPost,
+			path:   "/files/a/b",
+			want: `static node "/" (static) matches
+static node "f" (static) matches
+static node "iles" (static) matches
+static node "/" (static) matches
+wildcard node "*" (wildcard) captures "a/b"
+node "*" (wildcard) has no handler for method "POST"
+backtrack from node "*" (wildcard)
+backtrack from node "/" (static)
+backtrack from node "iles" (static)
+backtrack from node "f" (static)
+result: method not allowed "/files/*filepath"
+`,
+		},
+		{
+			method: http.MethodGet,
+			path:   "/files",
+			want: `static node "/" (static) matches
+static node "f" (static) matches
+static node "iles" (static) matches
+node "iles" (static) has handler for method "GET"
+result: trailing slash redirect
+`,
+		},
+		{
+			method: http.MethodGet,
+			path:   "/foo",
+			want: `static node "/" (static) matches
+static node "f" (static) matches
+static node "oo" (static) matches
+matchers of node "oo" (static) reject the request with status 404
+node "oo" (static) has no handler for method "GET"
+backtrack from node "oo" (static)
+backtrack from node "f" (static)
+result: not found
+`,
+		},
+		{
+			method: http.MethodPut,
+			path:   "/bar",
+			want: `static node "/" (static) matches
+static node "bar" (static) matches
+matchers of node "bar" (static) reject the request with status 415
+node "bar" (static) has no handler for method "PUT"
+backtrack from node "bar" (static)
+result: unsupported media type
+`,
+		},
+	} {
+		if got := sr.Explain(tt.method, tt.path); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRouterMatch_catchAll(t *testing.T) {
+	r := &Router{}
+	r.Handle("", "/a/:b/x/:c<[0-9]+>", http.NotFoundHandler())
+
+	for _, tt := range []struct {
+		path        string
+		wantStatus  MatchStatus
+		wantPattern string
+	}{
+		{"/a/1/x/", MatchNotFound, ""},
+		{"/a/1/x", MatchNotFound, ""},
+		{"/a/1", MatchNotFound, ""},
+		{"/a/1/x/2", MatchFound, "/a/:b/x/:c<[0-9]+>"},
+	} {
+		mr := r.Match(http.MethodGet, tt.path)
+		if got, want := mr.Status, tt.wantStatus; got != want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, want)
+		} else if got, want := mr.Pattern, tt.wantPattern; got != want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, want)
+		}
+	}
+
+	if got, want := r.Explain(http.MethodGet, "/a/1/x/"), `static `+
+		`node "/a/" (static) matches
+param node ":" (param) captures "1"
+static node "/x/" (static) matches
+param node ":" (param <[0-9]+>) rejects ""
+backtrack from node "/x/" (static)
+backtrack from node ":" (param)
+result: not found
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterNotFoundHandler(t *testing.T) {
+	r := &Router{}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	r.notFoundHandler().ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "Not Found\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	r = &Router{
+		Middlewares: []Middleware{MiddlewareFunc(func(
+			next http.Handler,
+		) http.Handler {
+			return http.HandlerFunc(func(
+				rw http.ResponseWriter,
+				req *http.Request,
+			) {
+				next.ServeHTTP(rw, req)
+				fmt.Fprint(rw, "middleware")
+			})
+		})},
 		NotFoundHandler: http.HandlerFunc(func(
 			rw http.ResponseWriter,
 			req *http.Request,
 		) {
-			http.Error(rw, "r1: not found", http.StatusNotFound)
+			http.Error(rw, "custom", http.StatusNotFound)
 		}),
-		routeTree: &routeNode{
-			staticChildren: make([]*routeNode, 255),
-			methodHandlers: &methodHandlers{},
-		},
-		registeredRoutes: map[string]bool{},
 	}
 
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	r.notFoundHandler().ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "custom\nmiddleware"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestRouterMethodNotAllowedHandler(t *testing.T) {
+	r := &Router{}
+
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rec := httptest.NewRecorder()
-	r1.ServeHTTP(rec, req)
+	r.methodNotAllowedHandler().ServeHTTP(rec, req)
 	recr := rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "r1: not found\n"; string(b) != want {
+	} else if want := "Method Not Allowed\n"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 
-	r2 := &Router{
-		Parent: r1,
-		NotFoundHandler: http.HandlerFunc(func(
+	r = &Router{
+		Middlewares: []Middleware{MiddlewareFunc(func(
+			next http.Handler,
+		) http.Handler {
+			return http.HandlerFunc(func(
+				rw http.ResponseWriter,
+				req *http.Request,
+			) {
+				next.ServeHTTP(rw, req)
+				fmt.Fprint(rw, "middleware")
+			})
+		})},
+		MethodNotAllowedHandler: http.HandlerFunc(func(
 			rw http.ResponseWriter,
 			req *http.Request,
 		) {
-			http.Error(rw, "r2: not found", http.StatusNotFound)
+			http.Error(rw, "custom", http.StatusMethodNotAllowed)
 		}),
 	}
 
 	req = httptest.NewRequest(http.MethodGet, "/", nil)
 	rec = httptest.NewRecorder()
-	r2.ServeHTTP(rec, req)
+	r.methodNotAllowedHandler().ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "r1: not found\n"; string(b) != want {
+	} else if want := "custom\nmiddleware"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 }
 
-func TestRouterNotFoundHandler(t *testing.T) {
+func TestRouterNotAcceptableHandler(t *testing.T) {
 	r := &Router{}
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rec := httptest.NewRecorder()
-	r.notFoundHandler().ServeHTTP(rec, req)
+	r.notAcceptableHandler().ServeHTTP(rec, req)
 	recr := rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusNotAcceptable; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Not Found\n"; string(b) != want {
+	} else if want := "Not Acceptable\n"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 
@@ -2072,19 +5904,19 @@ func TestRouterNotFoundHandler(t *testing.T) {
 				fmt.Fprint(rw, "middleware")
 			})
 		})},
-		NotFoundHandler: http.HandlerFunc(func(
+		NotAcceptableHandler: http.HandlerFunc(func(
 			rw http.ResponseWriter,
 			req *http.Request,
 		) {
-			http.Error(rw, "custom", http.StatusNotFound)
+			http.Error(rw, "custom", http.StatusNotAcceptable)
 		}),
 	}
 
 	req = httptest.NewRequest(http.MethodGet, "/", nil)
 	rec = httptest.NewRecorder()
-	r.notFoundHandler().ServeHTTP(rec, req)
+	r.notAcceptableHandler().ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusNotFound; recr.StatusCode != want {
+	if want := http.StatusNotAcceptable; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
@@ -2093,18 +5925,18 @@ func TestRouterNotFoundHandler(t *testing.T) {
 	}
 }
 
-func TestRouterMethodNotAllowedHandler(t *testing.T) {
+func TestRouterUnsupportedMediaTypeHandler(t *testing.T) {
 	r := &Router{}
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rec := httptest.NewRecorder()
-	r.methodNotAllowedHandler().ServeHTTP(rec, req)
+	r.unsupportedMediaTypeHandler().ServeHTTP(rec, req)
 	recr := rec.Result()
-	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+	if want := http.StatusUnsupportedMediaType; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
-	} else if want := "Method Not Allowed\n"; string(b) != want {
+	} else if want := "Unsupported Media Type\n"; string(b) != want {
 		t.Errorf("got %q, want %q", b, want)
 	}
 
@@ -2120,19 +5952,23 @@ func TestRouterMethodNotAllowedHandler(t *testing.T) {
 				fmt.Fprint(rw, "middleware")
 			})
 		})},
-		MethodNotAllowedHandler: http.HandlerFunc(func(
+		UnsupportedMediaTypeHandler: http.HandlerFunc(func(
 			rw http.ResponseWriter,
 			req *http.Request,
 		) {
-			http.Error(rw, "custom", http.StatusMethodNotAllowed)
+			http.Error(
+				rw,
+				"custom",
+				http.StatusUnsupportedMediaType,
+			)
 		}),
 	}
 
 	req = httptest.NewRequest(http.MethodGet, "/", nil)
 	rec = httptest.NewRecorder()
-	r.methodNotAllowedHandler().ServeHTTP(rec, req)
+	r.unsupportedMediaTypeHandler().ServeHTTP(rec, req)
 	recr = rec.Result()
-	if want := http.StatusMethodNotAllowed; recr.StatusCode != want {
+	if want := http.StatusUnsupportedMediaType; recr.StatusCode != want {
 		t.Errorf("got %d, want %d", recr.StatusCode, want)
 	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
 		t.Fatalf("unexpected error %q", err)
@@ -2141,6 +5977,62 @@ func TestRouterMethodNotAllowedHandler(t *testing.T) {
 	}
 }
 
+func TestRouterAutoOPTIONSHandler(t *testing.T) {
+	r := &Router{}
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req = req.WithContext(context.WithValue(
+		req.Context(),
+		dataContextKey,
+		&data{allowedMethods: []string{"GET", "OPTIONS"}},
+	))
+	rec := httptest.NewRecorder()
+	r.autoOPTIONSHandler().ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusNoContent; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if got, want := recr.Header.Get("Allow"), "GET, "+
+		"OPTIONS"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r = &Router{
+		Middlewares: []Middleware{MiddlewareFunc(func(
+			next http.Handler,
+		) http.Handler {
+			return http.HandlerFunc(func(
+				rw http.ResponseWriter,
+				req *http.Request,
+			) {
+				rw.Header().Set("Foo", "bar")
+				next.ServeHTTP(rw, req)
+			})
+		})},
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/", nil)
+	rec = httptest.NewRecorder()
+	r.autoOPTIONSHandler().ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNoContent; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if got, want := recr.Header.Get("Foo"), "bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterAllowedMethods(t *testing.T) {
+	rn := &routeNode{methodHandlers: &methodHandlers{}}
+	rn.setHandler("", http.NotFoundHandler())
+
+	r := &Router{}
+	if got, want := strings.Join(r.allowedMethods(rn), ", "), "GET, "+
+		"HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, "+
+		"TRACE"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 func TestRouterTSRHandler(t *testing.T) {
 	r := &Router{}
 
@@ -2215,6 +6107,147 @@ func TestRouterTSRHandler(t *testing.T) {
 	}
 }
 
+func TestRouteName(t *testing.T) {
+	rnm := RouteName("foo")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rnm.ChainHTTPHandler(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		req *http.Request,
+	) {
+		fmt.Fprint(rw, "foobar")
+	})).ServeHTTP(rec, req)
+
+	recb := rec.Body.String()
+	if want := "foobar"; recb != want {
+		t.Errorf("got %q, want %q", recb, want)
+	}
+}
+
+func TestRouteError(t *testing.T) {
+	re := &RouteError{
+		Method: http.MethodGet,
+		Path:   "/foo",
+		Err:    ErrRouteAlreadyExists,
+		Detail: "route already exists",
+	}
+	if got, want := re.Error(), `r2: cannot register route "/foo" `+
+		`for method "GET": route already exists`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := re.Unwrap(), ErrRouteAlreadyExists; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	re = &RouteError{
+		Path:   "foo",
+		Err:    ErrInvalidRoutePath,
+		Detail: "route path must start with '/'",
+	}
+	if got, want := re.Error(), `r2: cannot register route "foo": `+
+		`route path must start with '/'`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestURLError(t *testing.T) {
+	ue := &URLError{Route: "foo", Err: ErrRouteNotFound}
+	if got, want := ue.Error(), `r2: cannot build url for route "foo": `+
+		`route not found`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	} else if got, want := ue.Unwrap(), ErrRouteNotFound; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	ue = &URLError{
+		Route:     "foo",
+		PathParam: "bar",
+		Err:       ErrMissingPathParam,
+	}
+	if got, want := ue.Error(), `r2: cannot build url for route "foo" `+
+		`with path parameter "bar": `+
+		`missing path parameter`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMatchingHandler(t *testing.T) {
+	mh := &matchingHandler{
+		routes: []*matchingRoute{{
+			matchers: []Matcher{MatchQuery("foo", "bar")},
+			handler: http.HandlerFunc(func(
+				rw http.ResponseWriter,
+				req *http.Request,
+			) {
+				fmt.Fprint(rw, "foobar")
+			}),
+		}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?foo=bar", nil)
+	rec := httptest.NewRecorder()
+	mh.ServeHTTP(rec, req)
+	recr := rec.Result()
+	if want := http.StatusOK; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	} else if b, err := ioutil.ReadAll(recr.Body); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if want := "foobar"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	mh.ServeHTTP(rec, req)
+	recr = rec.Result()
+	if want := http.StatusNotFound; recr.StatusCode != want {
+		t.Errorf("got %d, want %d", recr.StatusCode, want)
+	}
+
+	mh = mergeHandlers(
+		http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+		) {
+			fmt.Fprint(rw, "plain")
+		}),
+		mh,
+	).(*matchingHandler)
+	for _, tt := range []struct {
+		target string
+		want   string
+	}{
+		{"/?foo=bar", "foobar"},
+		{"/", "plain"},
+	} {
+		req = httptest.NewRequest(http.MethodGet, tt.target, nil)
+		rec = httptest.NewRecorder()
+		mh.ServeHTTP(rec, req)
+		if got, want := rec.Body.String(), tt.want; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestMatchStatusString(t *testing.T) {
+	for _, tt := range []struct {
+		ms   MatchStatus
+		want string
+	}{
+		{MatchNotFound, "not found"},
+		{MatchFound, "found"},
+		{MatchMethodNotAllowed, "method not allowed"},
+		{MatchNotAcceptable, "not acceptable"},
+		{MatchUnsupportedMediaType, "unsupported media type"},
+		{MatchTSR, "trailing slash redirect"},
+	} {
+		if got := tt.ms.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
 func TestRouteNodeAddChild(t *testing.T) {
 	rn := &routeNode{
 		staticChildren: make([]*routeNode, 255),
@@ -2234,7 +6267,7 @@ func TestRouteNodeAddChild(t *testing.T) {
 	})
 	if got := rn.staticChildren['a']; got == nil {
 		t.Fatal("unexpected nil")
-	} else if rn.paramChild == nil {
+	} else if len(rn.paramChildren) == 0 {
 		t.Fatal("unexpected nil")
 	} else if rn.wildcardParamChild == nil {
 		t.Fatal("unexpected nil")
@@ -2243,6 +6276,92 @@ func TestRouteNodeAddChild(t *testing.T) {
 	}
 }
 
+func TestRouteNodeNode(t *testing.T) {
+	r := &Router{}
+	r.Handle(http.MethodGet, "/foo/bar", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/foo/:baz", http.NotFoundHandler())
+
+	if n := r.loadTable().routeTree.node("/foo/bar", nil); n == nil {
+		t.Fatal("unexpected nil")
+	} else if got, want := n.pattern(""), "/foo/bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if n := r.loadTable().routeTree.node("/foo/", nil); n == nil {
+		t.Fatal("unexpected nil")
+	} else if got, want := n.pattern(""), "/foo/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if n := r.loadTable().routeTree.node(
+		"/foo/:",
+		[]*pathParamConstraint{nil},
+	); n == nil {
+		t.Fatal("unexpected nil")
+	} else if got, want := n.pattern(""), "/foo/:baz"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if n := r.loadTable().routeTree.node("/foo/baz", nil); n != nil {
+		t.Errorf("got %v, want nil", n)
+	}
+
+	if n := r.loadTable().routeTree.node("/bar", nil); n != nil {
+		t.Errorf("got %v, want nil", n)
+	}
+
+	if n := r.loadTable().routeTree.node("/foo/qux", nil); n != nil {
+		t.Errorf("got %v, want nil", n)
+	}
+
+	n := &routeNode{prefix: ":", typ: paramRouteNode}
+	if got, want := n.pattern(""), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouteNodeRemoveHandler(t *testing.T) {
+	methods := []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodConnect,
+		http.MethodOptions,
+		http.MethodTrace,
+		"FOO",
+		"",
+	}
+
+	r := &Router{}
+	for _, m := range methods {
+		r.Handle(m, "/", http.NotFoundHandler())
+	}
+
+	rn := r.loadTable().routeTree
+	if rn.removeHandler("_tsr") {
+		t.Error("want false")
+	}
+
+	for _, m := range methods {
+		if !rn.hasHandler(m) {
+			t.Errorf("%q: want true", m)
+		} else if !rn.removeHandler(m) {
+			t.Errorf("%q: want true", m)
+		} else if rn.hasHandler(m) {
+			t.Errorf("%q: want false", m)
+		} else if rn.removeHandler(m) {
+			t.Errorf("%q: want false", m)
+		}
+	}
+
+	if rn.hasAtLeastOneHandler {
+		t.Error("want false")
+	}
+}
+
 func TestRouteNodesHandler(t *testing.T) {
 	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 	})
@@ -2357,3 +6476,33 @@ func TestRouteNodesHandler(t *testing.T) {
 		t.Fatal("unexpected nil")
 	}
 }
+
+func BenchmarkRouter(b *testing.B) {
+	r := &Router{}
+	r.Handle(http.MethodGet, "/foo/bar", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/foo/:bar", http.NotFoundHandler())
+
+	for _, bb := range []struct {
+		name   string
+		target string
+		ctx    context.Context
+	}{
+		{"Static", "/foo/bar", context.Background()},
+		{"StaticContext", "/foo/bar", Context()},
+		{"Param", "/foo/baz", context.Background()},
+		{"ParamContext", "/foo/baz", Context()},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			req := httptest.NewRequest(
+				http.MethodGet,
+				bb.target,
+				nil,
+			).WithContext(bb.ctx)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Handler(req)
+			}
+		})
+	}
+}
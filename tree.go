@@ -0,0 +1,223 @@
+package r2
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WriteTree writes a text dump of the route radix trees of the r to the w, one
+// node per line in the order the [Router.Handler] tries them. Each line shows
+// the prefix and the type of a node, along with the constraint of its path
+// parameter, the methods of its routes and the path parameter names of them
+// if any. When at least one route is registered with a host, each tree is
+// headed by its host ("*" means routes registered without a host).
+//
+// It is intended for debugging, and its format may change in the future.
+func (r *Router) WriteTree(w io.Writer) error {
+	if r.Parent != nil {
+		return r.Parent.WriteTree(w)
+	}
+
+	var b bytes.Buffer
+	r.walkRouteTrees(func(host string, rt *routeNode) {
+		if host != "" {
+			b.WriteString("host: " + host + "\n")
+		}
+
+		var writeNode func(rn *routeNode, indent string, last bool)
+		writeNode = func(rn *routeNode, indent string, last bool) {
+			b.WriteString(indent)
+			if rn != rt {
+				if last {
+					b.WriteString("└── ")
+					indent += "    "
+				} else {
+					b.WriteString("├── ")
+					indent += "│   "
+				}
+			}
+
+			b.WriteString(strings.Join(rn.describe(), " ") + "\n")
+
+			children := rn.children()
+			for i, c := range children {
+				writeNode(c, indent, i == len(children)-1)
+			}
+		}
+
+		writeNode(rt, "", true)
+	})
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteTreeDOT is like the [Router.WriteTree], but writes the route radix trees
+// in the DOT language of the Graphviz, so that they can be rendered as graphs.
+func (r *Router) WriteTreeDOT(w io.Writer) error {
+	if r.Parent != nil {
+		return r.Parent.WriteTreeDOT(w)
+	}
+
+	var (
+		b   bytes.Buffer
+		nid int
+	)
+
+	b.WriteString("digraph r2 {\n")
+	b.WriteString("\tnode [shape=box];\n")
+	r.walkRouteTrees(func(host string, rt *routeNode) {
+		var pid int
+		if host != "" {
+			nid++
+			pid = nid
+			b.WriteString("\tn" + strconv.Itoa(pid) + " [label=" +
+				strconv.Quote(host) + ", shape=ellipse];\n")
+		}
+
+		var writeNode func(rn *routeNode, pid int)
+		writeNode = func(rn *routeNode, pid int) {
+			nid++
+			id := "n" + strconv.Itoa(nid)
+			label := strings.Join(rn.describe(), "\n")
+			b.WriteString("\t" + id + " [label=" +
+				strconv.Quote(label) + "];\n")
+			if pid > 0 {
+				b.WriteString("\tn" + strconv.Itoa(pid) +
+					" -> " + id + ";\n")
+			}
+
+			pid = nid
+			for _, c := range rn.children() {
+				writeNode(c, pid)
+			}
+		}
+
+		writeNode(rt, pid)
+	})
+	b.WriteString("}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// walkRouteTrees calls the f for each non-empty route radix tree of the r, with
+// the host of the tree ("*" means the default one). The host is empty if the r
+// has no host route radix trees.
+func (r *Router) walkRouteTrees(f func(host string, rt *routeNode)) {
+	tb := r.loadTable()
+	if tb.routeTree == nil {
+		return
+	}
+
+	if len(tb.hostRouteTrees) == 0 {
+		if tb.routeTree.prefix != "" {
+			f("", tb.routeTree)
+		}
+
+		return
+	}
+
+	for _, hrt := range tb.hostRouteTrees {
+		if hrt.routeTree.prefix != "" {
+			f(hrt.host, hrt.routeTree)
+		}
+	}
+
+	if tb.routeTree.prefix != "" {
+		f("*", tb.routeTree)
+	}
+}
+
+// children returns the child nodes of the rn in the order the [Router.Handler]
+// tries them.
+func (rn *routeNode) children() []*routeNode {
+	var children []*routeNode
+	for _, c := range rn.staticChildren {
+		if c != nil {
+			children = append(children, c)
+		}
+	}
+
+	children = append(children, rn.paramChildren...)
+	if rn.wildcardParamChild != nil {
+		children = append(children, rn.wildcardParamChild)
+	}
+
+	return children
+}
+
+// describe returns the description of the rn used by the [Router.WriteTree]
+// and the [Router.WriteTreeDOT]. The path parameter names of the routes for
+// specific methods are only described when they differ from the ones of the
+// first route.
+func (rn *routeNode) describe() []string {
+	typ := "(" + rn.typ.String()
+	if rn.pathParamConstraint != nil {
+		typ += " " + rn.pathParamConstraint.key
+	}
+
+	desc := []string{rn.prefix, typ + ")"}
+	if ms := rn.methods(); len(ms) > 0 {
+		desc = append(desc, "methods: "+strings.Join(ms, ", "))
+		if ppns := rn.pathParamNames; len(ppns) > 0 {
+			desc = append(desc, "params: "+strings.Join(ppns, ", "))
+		}
+
+		for _, m := range ms {
+			k := m
+			if k == "*" {
+				k = ""
+			}
+
+			if ppns, ok := rn.methodPathParamNames[k]; ok {
+				desc = append(desc, m+" params: "+
+					strings.Join(ppns, ", "))
+			}
+		}
+	}
+
+	return desc
+}
+
+// methods returns the methods of the routes of the rn. A catch-all route is
+// represented as "*", and a TSR (Trailing Slash Redirect) route is represented
+// as "TSR".
+func (rn *routeNode) methods() []string {
+	var (
+		mhs = rn.methodHandlers
+		ms  []string
+	)
+	for _, mh := range []methodHandler{
+		{http.MethodGet, mhs.get},
+		{http.MethodHead, mhs.head},
+		{http.MethodPost, mhs.post},
+		{http.MethodPut, mhs.put},
+		{http.MethodPatch, mhs.patch},
+		{http.MethodDelete, mhs.delete},
+		{http.MethodConnect, mhs.connect},
+		{http.MethodOptions, mhs.options},
+		{http.MethodTrace, mhs.trace},
+	} {
+		if mh.handler != nil {
+			ms = append(ms, mh.method)
+		}
+	}
+
+	for _, omh := range rn.otherMethodHandlers {
+		ms = append(ms, omh.method)
+	}
+
+	if rn.hasCatchAllHandler() {
+		if rn.catchAllHandler.method == "_tsr" {
+			ms = append(ms, "TSR")
+		} else {
+			ms = append(ms, "*")
+		}
+	}
+
+	return ms
+}
@@ -0,0 +1,168 @@
+package r2
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouterWriteTree(t *testing.T) {
+	r := &Router{}
+
+	var b bytes.Buffer
+	if err := r.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/:id:int", http.NotFoundHandler())
+	r.Handle(http.MethodPost, "/users/:id:int", http.NotFoundHandler())
+	r.Handle("FOO", "/users/:id:int", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/:name/posts", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/files/*filepath", http.NotFoundHandler())
+	r.Handle("", "/foo", http.NotFoundHandler())
+
+	sr := r.Sub("")
+
+	b.Reset()
+	if err := sr.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), `/ (static) methods: GET
+├── f (static)
+│   ├── iles (static) methods: TSR
+│   │   └── / (static)
+│   │       └── * (wildcard) methods: GET params: *filepath
+│   └── oo (static) methods: *
+└── users/ (static)
+    ├── : (param :int) methods: GET, POST, FOO params: id
+    └── : (param)
+        └── /posts (static) methods: GET params: name
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	hr := &Router{Parent: r, Host: "{tenant}.example.com"}
+	hr.Handle(http.MethodGet, "/foo", http.NotFoundHandler())
+
+	b.Reset()
+	if err := r.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), `host: {tenant}.example.com
+/foo (static) methods: GET
+host: *
+/ (static) methods: GET
+├── f (static)
+│   ├── iles (static) methods: TSR
+│   │   └── / (static)
+│   │       └── * (wildcard) methods: GET params: *filepath
+│   └── oo (static) methods: *
+└── users/ (static)
+    ├── : (param :int) methods: GET, POST, FOO params: id
+    └── : (param)
+        └── /posts (static) methods: GET params: name
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r = &Router{Host: "example.com"}
+	r.Handle(http.MethodGet, "/foo", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/u/:a", http.NotFoundHandler())
+	r.Handle(http.MethodPut, "/u/:b", http.NotFoundHandler())
+	r.Handle("", "/u/:c", http.NotFoundHandler())
+
+	b.Reset()
+	if err := r.WriteTree(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), `host: example.com
+/ (static)
+├── foo (static) methods: GET
+└── u/ (static)
+    └── : (param) methods: GET, PUT, * params: a PUT params: b * params: c
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := r.WriteTree(errorWriter{}); err == nil {
+		t.Fatal("expected error")
+	} else if got, want := err, errWrite; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouterWriteTreeDOT(t *testing.T) {
+	r := &Router{}
+
+	var b bytes.Buffer
+	if err := r.WriteTreeDOT(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), `digraph r2 {
+	node [shape=box];
+}
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r.Handle(http.MethodGet, "/", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/:id<[0-9]+>", http.NotFoundHandler())
+	r.Handle(http.MethodGet, "/users/\"", http.NotFoundHandler())
+
+	hr := &Router{Parent: r, Host: "example.com"}
+	hr.Handle(http.MethodGet, "/foo", http.NotFoundHandler())
+
+	sr := r.Sub("")
+
+	b.Reset()
+	if err := sr.WriteTreeDOT(&b); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	} else if got, want := b.String(), `digraph r2 {
+	node [shape=box];
+	n1 [label="example.com", shape=ellipse];
+	n2 [label="/foo\n(static)\nmethods: GET"];
+	n1 -> n2;
+	n3 [label="*", shape=ellipse];
+	n4 [label="/\n(static)\nmethods: GET"];
+	n3 -> n4;
+	n5 [label="users/\n(static)"];
+	n4 -> n5;
+	n6 [label="\"\n(static)\nmethods: GET"];
+	n5 -> n6;
+	n7 [label=":\n(param <[0-9]+>)\nmethods: GET\nparams: id"];
+	n5 -> n7;
+}
+`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := r.WriteTreeDOT(errorWriter{}); err == nil {
+		t.Fatal("expected error")
+	} else if got, want := err, errWrite; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRouteNodeMethods(t *testing.T) {
+	rn := &routeNode{
+		methodHandlers:  &methodHandlers{},
+		catchAllHandler: &methodHandler{},
+	}
+	if got := rn.methods(); got != nil {
+		t.Errorf("got %q, want nil", got)
+	}
+
+	rn.catchAllHandler.handler = http.NotFoundHandler()
+	if got, want := strings.Join(rn.methods(), ","), "*"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+var errWrite = errors.New("write error")
+
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}